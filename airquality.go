@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// AirQuality is the current air pollution reading for a location
+type AirQuality struct {
+	AQI  int     `json:"aqi"`
+	PM25 float64 `json:"pm2_5"`
+	PM10 float64 `json:"pm10"`
+	O3   float64 `json:"o3"`
+}
+
+// airPollutionResponse mirrors the OpenWeather Air Pollution API response
+type airPollutionResponse struct {
+	List []struct {
+		Main struct {
+			AQI int `json:"aqi"`
+		} `json:"main"`
+		Components struct {
+			PM25 float64 `json:"pm2_5"`
+			PM10 float64 `json:"pm10"`
+			O3   float64 `json:"o3"`
+		} `json:"components"`
+	} `json:"list"`
+}
+
+// Qualitative bands for the OpenWeather air quality index, which runs from 1 to 5
+var aqiBands = []string{"Good", "Fair", "Moderate", "Poor", "Very Poor"}
+
+// Band returns the qualitative description of the index
+func (a *AirQuality) Band() string {
+	if a.AQI < 1 || a.AQI > len(aqiBands) {
+		return "Unknown"
+	}
+	return aqiBands[a.AQI-1]
+}
+
+// Describe the air quality in a sentence for the weather summary
+func (a *AirQuality) String() string {
+	return fmt.Sprintf("Air quality is %s (AQI %d), with PM2.5 at %.1f μg/m³, PM10 at %.1f μg/m³ and O3 at %.1f μg/m³.",
+		a.Band(), a.AQI, a.PM25, a.PM10, a.O3)
+}
+
+// Fetch the current air quality at the given coordinates from the OpenWeather Air Pollution API
+func fetchAirQuality(ctx context.Context, lat, lon float64) (*AirQuality, error) {
+	apiKey, err := getAPIKey("WEATHER_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/data/2.5/air_pollution?lat=%s&lon=%s&appid=%s",
+		openWeatherBaseURL, strconv.FormatFloat(lat, 'f', -1, 64), strconv.FormatFloat(lon, 'f', -1, 64), apiKey)
+
+	var resp airPollutionResponse
+	if err := getJSON(ctx, url, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.List) == 0 {
+		return nil, fmt.Errorf("unexpected response format: air pollution 'list' missing or empty")
+	}
+
+	entry := resp.List[0]
+	return &AirQuality{
+		AQI:  entry.Main.AQI,
+		PM25: entry.Components.PM25,
+		PM10: entry.Components.PM10,
+		O3:   entry.Components.O3,
+	}, nil
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Assistant wires together the LLM, the weather provider and the configuration
+// used to answer weather questions. LLM is nil when running without one.
+//
+// An Assistant is safe for concurrent use: its fields are not modified after
+// creation, per-question state lives in the Report and Conversation, and the
+// shared HTTP client, cache and rate limiter are safe for concurrent use
+type Assistant struct {
+	LLM      LLMClient
+	Provider WeatherProvider
+	Config   *Config
+
+	// Confirm, when set, asks the user whether a geocoded place that looks different
+	// from the one they named is the one they meant. Only the REPL sets it
+	Confirm func(ctx context.Context, question string) bool
+}
+
+// Create an assistant from its dependencies
+func NewAssistant(cfg *Config, llm LLMClient, provider WeatherProvider) *Assistant {
+	return &Assistant{LLM: llm, Provider: provider, Config: cfg}
+}
+
+// Print an intermediate step to stderr in -verbose mode, keeping stdout for the answer
+func (a *Assistant) verbosef(format string, args ...interface{}) {
+	if a.Config.Verbose {
+		fmt.Fprintf(os.Stderr, "> "+format+"\n", args...)
+	}
+}
+
+// ExtractCity works out which location the user is asking about, returning how it was found
+// and the tokens spent doing so. A city given with -city wins over the question
+func (a *Assistant) ExtractCity(ctx context.Context, userMessage string, history []Message) (Location, ExtractionMethod, Usage, error) {
+	if a.Config.City != "" {
+		return parseQualifiedCity(a.Config.City), MethodFlag, Usage{}, nil
+	}
+	return extractLocation(ctx, a.LLM, a.Config.ExtractPrompt, history, userMessage)
+}
+
+// FetchWeather fetches the current weather for loc from the configured provider
+func (a *Assistant) FetchWeather(ctx context.Context, loc Location) (*WeatherData, error) {
+	return a.Provider.Current(ctx, loc)
+}
+
+// FetchForecast fetches the forecast for loc from the configured provider, failing with
+// ErrNoForecast when it has none
+func (a *Assistant) FetchForecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	p, ok := a.Provider.(ForecastProvider)
+	if !ok {
+		return nil, ErrNoForecast
+	}
+	return p.Forecast(ctx, loc)
+}
+
+// GenerateResponse asks the LLM to answer the user's question from the weather information,
+// streaming the answer to onToken when it is not nil
+func (a *Assistant) GenerateResponse(ctx context.Context, userMessage, weatherInfo string, history []Message, onToken func(string)) (string, Usage, error) {
+	return generateWeatherResponse(ctx, a.LLM, a.Config, history, userMessage, weatherInfo, onToken)
+}
+
+// Report is the weather looked up for one question, before the LLM phrases an answer
+type Report struct {
+	Location   Location
+	Method     ExtractionMethod // how the location was found in the question
+	Weather    *WeatherData
+	Forecast   *ForecastData
+	AirQuality *AirQuality
+	Note       string
+	Usage      Usage // LLM tokens spent so far on the question
+	Days       []int // days the question asks about, as offsets from today, nil for all
+	DayCount   int   // number of forecast days summarized when Days is nil, 0 for all
+}
+
+// PromptInfo formats the report into the text handed to the LLM: the current weather as
+// labeled fields, so the model can answer detailed questions, and forecasts as in Summary
+func (r *Report) PromptInfo(f Formatter) (string, error) {
+	if r.Forecast != nil {
+		return r.Summary(f)
+	}
+	info, err := formatWeatherFields(r.Weather, f)
+	if err != nil {
+		return "", err
+	}
+	if r.AirQuality != nil {
+		info += "\n" + r.AirQuality.String()
+	}
+	return info, nil
+}
+
+// Summary formats the report into readable text, which is the answer without an LLM
+func (r *Report) Summary(f Formatter) (string, error) {
+	var summary string
+	var err error
+	switch {
+	case r.Forecast != nil && r.Days != nil:
+		summary, err = formatForecastDays(r.Forecast, r.Days, r.Forecast.localDate(time.Now()), f)
+	case r.Forecast != nil:
+		summary, err = formatForecastResponse(r.Forecast, r.DayCount, f)
+	default:
+		summary, err = formatWeatherResponse(r.Weather, f)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if r.AirQuality != nil {
+		summary += "\n" + r.AirQuality.String()
+	}
+	return summary, nil
+}
+
+// Work out the coordinates of the report's location, from the query itself, the
+// weather response, or failing that the geocoding API
+func (r *Report) coordinates(ctx context.Context) (lat, lon float64, err error) {
+	switch {
+	case r.Location.HasCoords:
+		return r.Location.Lat, r.Location.Lon, nil
+	case r.Weather != nil && r.Weather.Coord != nil:
+		return r.Weather.Coord.Lat, r.Weather.Coord.Lon, nil
+	case r.Forecast != nil && r.Forecast.City.Coord != nil:
+		return r.Forecast.City.Coord.Lat, r.Forecast.City.Coord.Lon, nil
+	}
+
+	matches, err := geocodeCity(ctx, r.Location)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(matches) == 0 {
+		return 0, 0, fmt.Errorf("%w: %s", ErrCityNotFound, r.Location)
+	}
+	return matches[0].Lat, matches[0].Lon, nil
+}
+
+// Locate the user by IP address when -auto-location is on, returning the zero
+// Location when it is off or the lookup fails
+func (a *Assistant) locateUser(ctx context.Context) Location {
+	if !a.Config.AutoLocation {
+		return Location{}
+	}
+	loc, err := locateByIP(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "could not work out your location, please name a city", "error", err)
+		return Location{}
+	}
+	return loc
+}
+
+// Catch typos and hallucinated cities by comparing the extracted name with the best
+// geocoding match. A poor match is confirmed with the user when a.Confirm is set, and
+// otherwise only logged
+func (a *Assistant) checkMatch(ctx context.Context, extracted, match Location) error {
+	similarity := nameSimilarity(extracted.Name, match.Name)
+	if similarity >= minNameSimilarity {
+		return nil
+	}
+	if a.Confirm == nil {
+		slog.WarnContext(ctx, "low confidence in geocoded location", "extracted", extracted.Name, "match", match.String(), "similarity", similarity)
+		return nil
+	}
+	// The user's time to reply is not taken from the fetch and generate budget
+	resume := pauseDeadline(ctx)
+	ok := a.Confirm(ctx, fmt.Sprintf("Did you mean %s?", strings.Join(strings.Split(match.qualifiedName(), ","), ", ")))
+	resume()
+	if !ok {
+		return fmt.Errorf("%w: %s did not match %s", ErrInvalidCity, extracted.Name, match)
+	}
+	return nil
+}
+
+// Lookup extracts the location from the question and fetches its weather (or forecast,
+// in forecast mode). conv, which may be nil, supplies the context of earlier questions
+func (a *Assistant) Lookup(ctx context.Context, userMessage string, conv *Conversation) (*Report, error) {
+	ctx, cancel := withQuestionTimeout(ctx, a.Config.Timeout)
+	defer cancel()
+
+	// Step 1: Extract the location from the user's message
+	loc, method, usage, err := a.ExtractCity(ctx, userMessage, conv.history())
+	if errors.Is(err, ErrNoCity) && a.LLM != nil {
+		// The model already looked at the earlier conversation, so the user's own
+		// location is all there is to fall back to
+		ipLoc := a.locateUser(ctx)
+		if ipLoc.String() == "" {
+			return nil, err
+		}
+		loc, method, err = ipLoc, MethodIP, nil
+	}
+	if err != nil || loc.String() == "" {
+		// Follow-up questions often omit the city, so fall back to the previous one
+		fallback, fallbackMethod := conv.lastLocation(), MethodPrevious
+		if fallback.String() == "" {
+			fallback, fallbackMethod = a.locateUser(ctx), MethodIP
+		}
+		if fallback.String() == "" {
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract city: %w", err)
+			}
+			return nil, fmt.Errorf("could not extract city from your input")
+		}
+		slog.InfoContext(ctx, "no city found in input, using fallback location", "location", fallback.String(), "method", fallbackMethod)
+		loc, method = fallback, fallbackMethod
+	}
+	//log the extracted location
+	slog.InfoContext(ctx, "extracted location", "location", loc.String(), "method", method)
+	extractionsTotal.WithLabelValues(string(method)).Inc()
+
+	report := &Report{Method: method, Usage: usage, DayCount: a.Config.Days}
+	if err := a.lookupLocation(ctx, report, loc, userMessage); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// LookupLocation fetches the weather (or forecast, in forecast mode) for a location that
+// needs no extraction, such as a line of the -batch file
+func (a *Assistant) LookupLocation(ctx context.Context, loc Location, method ExtractionMethod) (*Report, error) {
+	ctx, cancel := withQuestionTimeout(ctx, a.Config.Timeout)
+	defer cancel()
+
+	report := &Report{Method: method, DayCount: a.Config.Days}
+	if err := a.lookupLocation(ctx, report, loc, ""); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Geocode loc and fill report with its weather or forecast. A userMessage asking about
+// a later day, e.g. "tomorrow", gets the forecast for that day; it is empty when there
+// was no question
+func (a *Assistant) lookupLocation(ctx context.Context, report *Report, loc Location, userMessage string) error {
+	var err error
+	// Resolve city names to the coordinates of the best geocoding match, which is more
+	// accurate than letting OpenWeather pick from the name alone. Postal codes are
+	// already precise and not accepted by the geocoder
+	if primary := a.Config.primaryProvider(); (primary == ProviderOpenWeather || primary == ProviderOneCall) && !loc.HasCoords && loc.Zip == "" {
+		matches, err := geocodeCity(ctx, loc)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "could not geocode location, querying by name", "location", loc.String(), "error", err)
+		case len(matches) == 0:
+			return fmt.Errorf("failed to fetch weather data: %w: %s", ErrCityNotFound, loc)
+		default:
+			// Let the user know when an unqualified city name matches several places
+			if loc.Country == "" {
+				report.Note = ambiguityNote(loc, matches)
+			}
+			if err := a.checkMatch(ctx, loc, matches[0]); err != nil {
+				return err
+			}
+			loc = matches[0]
+			slog.DebugContext(ctx, "geocoded location", "location", loc.String(), "lat", loc.Lat, "lon", loc.Lon)
+		}
+	}
+	report.Location = loc
+	a.verbosef("city: %s (via %s)", loc, report.Method)
+
+	// A question about a later day, e.g. "tomorrow", needs the forecast for that day.
+	// Until the forecast tells the city's date, weekdays are counted from the server's
+	if userMessage != "" {
+		if days := parseRelativeDays(userMessage, time.Now().Weekday()); hasFutureDay(days) {
+			if err := checkForecastHorizon(days); err != nil {
+				return err
+			}
+			report.Days = days
+		}
+	}
+
+	// Step 2: Fetch the weather (or forecast) data for the extracted city
+	if a.Config.Forecast || report.Days != nil {
+		report.Forecast, err = a.FetchForecast(ctx, loc)
+		if err != nil {
+			return fmt.Errorf("failed to fetch forecast data: %w", err)
+		}
+
+		// The city may already be on another day than the server
+		if report.Days != nil {
+			report.Days = parseRelativeDays(userMessage, report.Forecast.localDate(time.Now()).Weekday())
+			if err := checkForecastHorizon(report.Days); err != nil {
+				return err
+			}
+			a.verbosef("days: %v", report.Days)
+		}
+	} else {
+		report.Weather, err = a.FetchWeather(ctx, loc)
+		if err != nil {
+			return fmt.Errorf("failed to fetch weather data: %w", err)
+		}
+
+		// A change in pressure hints at changing weather
+		if m := report.Weather.Main; m != nil && m.Pressure != nil {
+			if trend := pressureTrend(loc.key(), *m.Pressure, time.Now()); trend != "" {
+				// The data may be shared with the cache, so annotate a copy
+				w := *report.Weather
+				w.PressureTrend = trend
+				report.Weather = &w
+			}
+		}
+	}
+
+	// Air quality is a nice-to-have, so a failure here doesn't fail the whole question
+	if a.Config.AQI {
+		if err := a.addAirQuality(ctx, report); err != nil {
+			slog.WarnContext(ctx, "could not fetch air quality", "location", loc.String(), "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Look up the air quality for the report's location and attach it to the report
+func (a *Assistant) addAirQuality(ctx context.Context, report *Report) error {
+	// Offline mode makes the reading up, so there are no coordinates to geocode either
+	if offlineMode {
+		report.AirQuality = offlineAirQuality(report.Location)
+		return nil
+	}
+	lat, lon, err := report.coordinates(ctx)
+	if err != nil {
+		return err
+	}
+	report.AirQuality, err = fetchAirQuality(ctx, lat, lon)
+	return err
+}
+
+// Result is the outcome of running one question through the pipeline
+type Result struct {
+	Location Location
+	Weather  *WeatherData // nil in forecast mode
+	Response string
+	Usage    Usage
+}
+
+// Answer runs a question through extraction, weather lookup and response generation.
+// conv, which may be nil, supplies the context of earlier questions
+func (a *Assistant) Answer(ctx context.Context, userMessage string, conv *Conversation) (*Result, error) {
+	ctx, cancel := withQuestionTimeout(ctx, a.Config.Timeout)
+	defer cancel()
+
+	report, err := a.Lookup(ctx, userMessage, conv)
+	if err != nil {
+		return nil, err
+	}
+	return a.Respond(ctx, report, userMessage, conv, nil)
+}
+
+// Respond phrases the answer to a question from the report looked up for it. When onToken
+// is not nil, the LLM's answer is streamed to it while it is generated; the report's note
+// and an answer made without the LLM are only part of the result
+func (a *Assistant) Respond(ctx context.Context, report *Report, userMessage string, conv *Conversation, onToken func(string)) (*Result, error) {
+	weatherInfo, err := report.Summary(a.Config.Formatter())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format weather: %w", err)
+	}
+
+	// Step 3: Generate the final response using the LLM, or answer with the
+	// formatted summary as-is when there is none
+	a.verbosef("weather summary: %s", weatherInfo)
+
+	response := weatherInfo
+	if a.LLM != nil {
+		promptInfo, err := report.PromptInfo(a.Config.Formatter())
+		if err != nil {
+			return nil, fmt.Errorf("failed to format weather: %w", err)
+		}
+		a.verbosef("model: %s", a.Config.Model)
+		var usage Usage
+		response, usage, err = a.GenerateResponse(ctx, userMessage, promptInfo, conv.history(), onToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate response: %w", err)
+		}
+		report.Usage.Add(usage)
+		a.verbosef("token usage: %s", report.Usage)
+	}
+
+	if report.Note != "" {
+		response += "\n\n" + report.Note
+	}
+	return &Result{Location: report.Location, Weather: report.Weather, Response: response, Usage: report.Usage}, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAssistantExtractCity(t *testing.T) {
+	tests := []struct {
+		question   string
+		want       string
+		wantMethod ExtractionMethod
+		wantErr    bool
+	}{
+		{"How warm is it in London?", "London", MethodLLM, false},
+		{"Is it raining in Paris?", "Paris", MethodLLM, false},
+		{"Tokyo", "Tokyo", MethodBareCity, false},
+		{"What about 48.85, 2.35?", "48.8500,2.3500", MethodCoordinates, false},
+		{"Will I need an umbrella?", "", "", true},
+	}
+	for _, tt := range tests {
+		a := NewAssistant(testConfig(t), &fakeLLM{}, newFakeProvider())
+		loc, method, _, err := a.ExtractCity(context.Background(), tt.question, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExtractCity(%q) error = %v, wantErr %v", tt.question, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if loc.String() != tt.want || method != tt.wantMethod {
+			t.Errorf("ExtractCity(%q) = %s by %s, want %s by %s", tt.question, loc, method, tt.want, tt.wantMethod)
+		}
+	}
+}
+
+func TestAssistantAnswer(t *testing.T) {
+	tests := []struct {
+		name         string
+		llm          *fakeLLM
+		question     string
+		conv         *Conversation
+		wantLoc      string
+		wantResponse string
+		wantErr      error
+	}{
+		{"extracted city", &fakeLLM{}, "How warm is it in London?", nil, "London", "Answer: Location: London\n", nil},
+		{"previous city", &fakeLLM{}, "and is it windy?", &Conversation{LastLocation: Location{Name: "Tokyo"}, History: []Message{{Role: "user", Content: "How cold is it in Tokyo?"}}}, "Tokyo", "Answer: Location: Tokyo\n", nil},
+		{"unknown city", &fakeLLM{complete: func(system, user string) (string, error) { return `"Atlantis"`, nil }}, "Atlantis", nil, "", "", ErrCityNotFound},
+		{"LLM failure", &fakeLLM{complete: func(system, user string) (string, error) {
+			if system == defaultExtractPrompt {
+				return `"London"`, nil
+			}
+			return "", errBoom
+		}}, "How warm is it in London?", nil, "", "", errBoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newFakeProvider()
+			a := NewAssistant(testConfig(t), tt.llm, provider)
+
+			result, err := a.Answer(context.Background(), tt.question, tt.conv)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Answer(%q) = %v, want %v", tt.question, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Answer(%q): %v", tt.question, err)
+			}
+			if result.Location.String() != tt.wantLoc {
+				t.Errorf("location = %s, want %s", result.Location, tt.wantLoc)
+			}
+			if !strings.HasPrefix(result.Response, tt.wantResponse) {
+				t.Errorf("response = %q, want it to start with %q", result.Response, tt.wantResponse)
+			}
+			// One call to extract the city, one to answer
+			if result.Usage.TotalTokens != 2*fakeUsage.TotalTokens {
+				t.Errorf("usage = %d tokens, want %d", result.Usage.TotalTokens, 2*fakeUsage.TotalTokens)
+			}
+			if len(provider.asked) != 1 || provider.asked[0] != tt.wantLoc {
+				t.Errorf("provider asked about %q, want just %s", provider.asked, tt.wantLoc)
+			}
+		})
+	}
+}
+
+func TestAssistantAnswerNoCity(t *testing.T) {
+	provider := newFakeProvider()
+	a := NewAssistant(testConfig(t), &fakeLLM{}, provider)
+	if _, err := a.Answer(context.Background(), "Will I need an umbrella?", nil); !errors.Is(err, ErrNoCity) {
+		t.Fatalf("Answer without a city or a previous one = %v, want %v", err, ErrNoCity)
+	}
+	if len(provider.asked) != 0 {
+		t.Errorf("provider asked about %q, want no lookup", provider.asked)
+	}
+}
+
+// Error the fake LLM fails with
+// Forecasts come from the configured provider, or a chain's first one with a forecast
+func TestAssistantLookupForecast(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider WeatherProvider
+		forecast bool // -forecast
+		question string
+		wantErr  error
+	}{
+		{"forecast mode", &OfflineProvider{}, true, "Paris", nil},
+		{"later day", &OfflineProvider{}, false, "Will it rain in Paris tomorrow?", nil},
+		{"chain", &ChainProvider{Names: []string{"fake", ProviderOffline}, Providers: []WeatherProvider{newFakeProvider(), &OfflineProvider{}}}, true, "Paris", nil},
+		{"no forecast", newFakeProvider(), true, "Paris", ErrNoForecast},
+		{"no forecast for a later day", newFakeProvider(), false, "Will it rain in Paris tomorrow?", ErrNoForecast},
+		{"chain without forecast", &ChainProvider{Names: []string{"fake"}, Providers: []WeatherProvider{newFakeProvider()}}, true, "Paris", ErrNoForecast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ow := newMockOpenWeather(t)
+			cfg := testConfig(t)
+			cfg.Forecast = tt.forecast
+			a := NewAssistant(cfg, &fakeLLM{}, tt.provider)
+
+			report, err := a.Lookup(context.Background(), tt.question, nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Lookup(%q) = %v, want %v", tt.question, err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Lookup(%q): %v", tt.question, err)
+			} else if report.Forecast == nil || len(report.Forecast.List) == 0 {
+				t.Errorf("Lookup(%q) has no forecast", tt.question)
+			}
+			if n := ow.requests.Load(); n != 0 {
+				t.Errorf("%d requests to OpenWeather, want none", n)
+			}
+		})
+	}
+}
+
+var errBoom = errors.New("boom")
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// batchResult is the outcome of looking up one line of the batch file
+type batchResult struct {
+	city   string
+	report *Report
+	err    error
+}
+
+// Read the cities to look up from a batch file, one per line, skipping blank lines and # comments
+func readBatchFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cities []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cities = append(cities, line)
+	}
+	return cities, scanner.Err()
+}
+
+// Look up the weather for every city in the batch file and print one result per
+// city, in file order. Each line is taken as a location, the way -city is, so
+// there is no extraction. It returns the exit code: non-zero if any city failed
+func runBatch(ctx context.Context, cfg *Config, assistant *Assistant, w io.Writer) int {
+	cities, err := readBatchFile(cfg.Batch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading batch file:", err)
+		return exitConfig
+	}
+
+	// Requests still go through the rate limiter, the pool only caps how many are in flight
+	results := make([]batchResult, len(cities))
+	for i, city := range cities {
+		results[i] = batchResult{city: city, err: ctx.Err()}
+	}
+	forEachLimit(ctx, cfg.Concurrency, len(cities), func(i int) {
+		loc, err := parseLocation(cities[i])
+		if err != nil {
+			results[i] = batchResult{city: cities[i], err: err}
+			return
+		}
+		report, err := assistant.LookupLocation(withRequestID(ctx, newRequestID()), loc, MethodBatch)
+		results[i] = batchResult{city: cities[i], report: report, err: err}
+	})
+
+	code := exitOK
+	var reports []jsonReport
+	for _, r := range results {
+		if r.err != nil {
+			code = exitCode(r.err)
+		}
+
+		if cfg.JSON {
+			if r.err != nil {
+				reports = append(reports, jsonReport{City: r.city, Timestamp: time.Now().UTC(), Units: cfg.Units, Error: r.err.Error()})
+			} else {
+				reports = append(reports, newJSONReport(r.report, cfg.Units))
+			}
+			continue
+		}
+
+		if r.err != nil {
+			fmt.Fprintf(w, "%s: %s\n", r.city, userErrorMessage(r.err))
+			continue
+		}
+		summary, err := r.report.Summary(cfg.Formatter())
+		if err != nil {
+			code = exitUpstream
+			fmt.Fprintf(w, "%s: Error: %v\n", r.city, err)
+			continue
+		}
+		// Keep one line per city
+		fmt.Fprintf(w, "%s: %s\n", r.city, strings.ReplaceAll(summary, "\n", " "))
+	}
+
+	if cfg.JSON {
+		if err := writeIndentedJSON(w, reports); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitFailure
+		}
+	}
+	return code
+}
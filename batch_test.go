@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Lines are locations as given with -city, so commas qualify the city even without the LLM
+func TestRunBatchWithoutLLM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cities.txt")
+	body := "# cities\nSan Francisco, CA, US\n\nParis\nwww.paris.fr\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig(t)
+	cfg.Batch, cfg.Concurrency = path, 1
+	provider := newFakeProvider()
+	provider.weather["San Francisco"] = testWeather("San Francisco", 16, "fog")
+
+	var out strings.Builder
+	code := runBatch(context.Background(), cfg, NewAssistant(cfg, nil, provider), &out)
+	if code == exitOK {
+		t.Errorf("runBatch exit code = %d, want non-zero for the invalid line", code)
+	}
+	if want := []string{"San Francisco", "Paris"}; !slices.Equal(provider.asked, want) {
+		t.Errorf("provider asked about %q, want %q", provider.asked, want)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	wantPrefixes := []string{"San Francisco, CA, US: ", "Paris: ", "www.paris.fr: "}
+	if len(lines) != len(wantPrefixes) {
+		t.Fatalf("output:\n%s\nwant %d lines", out.String(), len(wantPrefixes))
+	}
+	for i, prefix := range wantPrefixes {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want it to start with %q", i+1, lines[i], prefix)
+		}
+	}
+	if !strings.Contains(lines[0], "fog") {
+		t.Errorf("line 1 = %q, want the weather of San Francisco", lines[0])
+	}
+}
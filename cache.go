@@ -0,0 +1,115 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Default time a cached weather result stays fresh
+const defaultCacheTTL = 10 * time.Minute
+
+// Default maximum number of results kept in the cache
+const defaultCacheSize = 1000
+
+// Place names rarely move, so geocoding results are kept much longer than the weather
+const defaultGeocodeCacheTTL = 24 * time.Hour
+
+// ttlCache is an in-memory, concurrency-safe cache of values such as weather results.
+// Values expire after the TTL, and once the cache is full the least recently used one is evicted
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List    // most recently used at the front
+	disk       *diskStore[V] // optional second level that survives between runs
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	data    V
+	expires time.Time
+}
+
+// Weather cache consulted by fetchWeatherData, keyed by location, units and language. nil when caching is disabled
+var currentCache = newTTLCache[*WeatherData](defaultCacheTTL, defaultCacheSize)
+
+// Geocoding cache consulted by geocodeCity, keyed by normalized location. nil when caching is disabled
+var geocodeCache = newTTLCache[[]Location](defaultGeocodeCacheTTL, defaultCacheSize)
+
+func newTTLCache[V any](ttl time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Build the weather cache key from the normalized location, units and language
+func cacheKey(loc Location, units Units, lang string) string {
+	return loc.key() + "|" + string(units) + "|" + lang
+}
+
+// Return the cached result for key if present and not expired
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return c.getDisk(key)
+	}
+	entry := elem.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.data, true
+}
+
+// Look key up in the persistent cache, if any. A hit is kept in memory until it expires
+// on disk, so it is not served for longer than the TTL. The caller holds c.mu
+func (c *ttlCache[V]) getDisk(key string) (V, bool) {
+	var zero V
+	if c.disk == nil {
+		return zero, false
+	}
+	data, expires, ok := c.disk.Get(key)
+	if !ok {
+		return zero, false
+	}
+	c.set(key, data, expires)
+	return data, true
+}
+
+// Store a result under key for the cache TTL, evicting the least recently used
+// result if the cache is full
+func (c *ttlCache[V]) Set(key string, data V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, data, time.Now().Add(c.ttl))
+	if c.disk != nil {
+		c.disk.Set(key, data)
+	}
+}
+
+// Store a result in memory until expires. The caller holds c.mu
+func (c *ttlCache[V]) set(key string, data V, expires time.Time) {
+	entry := &cacheEntry[V]{key: key, data: data, expires: expires}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Drop an element from both the map and the usage order. The caller holds c.mu
+func (c *ttlCache[V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry[V]).key)
+}
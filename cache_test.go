@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// List the keys in the cache, most recently used first, without touching the usage order
+func cachedKeys(c *ttlCache[int]) []string {
+	var keys []string
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*cacheEntry[int]).key)
+	}
+	return keys
+}
+
+func TestTTLCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTTLCache[int](time.Minute, 3)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// Reading a makes b the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v, want 1, true", v, ok)
+	}
+	c.Set("d", 4)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was kept, want it evicted as the least recently used")
+	}
+
+	// Overwriting c makes a the least recently used
+	c.Set("c", 30)
+	c.Set("e", 5)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a was kept, want it evicted as the least recently used")
+	}
+
+	want := []string{"e", "c", "d"}
+	if got := cachedKeys(c); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("cache holds %v, most recent first, want %v", got, want)
+	}
+	if v, _ := c.Get("c"); v != 30 {
+		t.Errorf("Get(c) = %d, want the overwritten 30", v)
+	}
+}
+
+func TestTTLCacheExpires(t *testing.T) {
+	c := newTTLCache[int](time.Minute, 3)
+	c.Set("old", 1)
+	c.entries["old"].Value.(*cacheEntry[int]).expires = time.Now().Add(-time.Second)
+	c.Set("new", 2)
+
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry was served")
+	}
+	if v, ok := c.Get("new"); !ok || v != 2 {
+		t.Errorf("Get(new) = %v, %v, want 2, true", v, ok)
+	}
+	if got := cachedKeys(c); len(got) != 1 {
+		t.Errorf("cache holds %v after the expired entry was read, want only new", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ChainProvider tries each provider in turn and returns the first success, so an
+// outage of the primary provider falls back to the next one
+type ChainProvider struct {
+	Names     []string
+	Providers []WeatherProvider
+}
+
+// Current fetches the current weather from the first provider that succeeds. When ctx
+// has a deadline, each attempt gets an equal share of the time that is left
+func (c *ChainProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	var data *WeatherData
+	err := tryProviders(ctx, loc, c.Names, c.Providers, func(ctx context.Context, p WeatherProvider) (err error) {
+		data, err = p.Current(ctx, loc)
+		return err
+	})
+	return data, err
+}
+
+// Forecast fetches the forecast from the first provider with a forecast that succeeds,
+// failing with ErrNoForecast when none of them has one
+func (c *ChainProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	var names []string
+	var forecasters []WeatherProvider
+	for i, p := range c.Providers {
+		if _, ok := p.(ForecastProvider); ok {
+			names, forecasters = append(names, c.Names[i]), append(forecasters, p)
+		}
+	}
+	if len(forecasters) == 0 {
+		return nil, ErrNoForecast
+	}
+
+	var data *ForecastData
+	err := tryProviders(ctx, loc, names, forecasters, func(ctx context.Context, p WeatherProvider) (err error) {
+		data, err = p.(ForecastProvider).Forecast(ctx, loc)
+		return err
+	})
+	return data, err
+}
+
+// Call fetch with each of providers in turn until one succeeds. names holds the name of
+// each provider, for logging
+func tryProviders(ctx context.Context, loc Location, names []string, providers []WeatherProvider, fetch func(context.Context, WeatherProvider) error) error {
+	var errs []error
+	for i, p := range providers {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			share := time.Until(deadline) / time.Duration(len(providers)-i)
+			attemptCtx, cancel = context.WithTimeout(ctx, share)
+		}
+		err := fetch(attemptCtx, p)
+		cancel()
+
+		if err == nil {
+			slog.InfoContext(ctx, "weather served by provider", "provider", names[i], "location", loc.String())
+			return nil
+		}
+		// A city one provider doesn't know is not an outage, and the caller is gone if ctx is done
+		if errors.Is(err, ErrCityNotFound) || ctx.Err() != nil {
+			return err
+		}
+
+		slog.WarnContext(ctx, "weather provider failed, trying the next one", "provider", names[i], "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+	}
+	return fmt.Errorf("all weather providers failed: %w", errors.Join(errs...))
+}
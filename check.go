@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Check that the configuration is usable without making any network calls, writing
+// one line per check to w. It returns false if anything needed is missing
+func runCheck(w io.Writer, cfg *Config) bool {
+	ok := true
+	report := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(w, "%-16s FAIL  %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(w, "%-16s ok\n", name)
+	}
+
+	// Flags and environment values were already parsed by loadConfig
+	report("config", nil)
+
+	_, err := newWeatherProvider(cfg)
+	report("provider", err)
+
+	if cfg.Offline {
+		fmt.Fprintf(w, "%-16s skipped (OFFLINE)\n", "API keys")
+		return ok
+	}
+
+	_, err = getAPIKey("WEATHER_API_KEY")
+	report("WEATHER_API_KEY", err)
+
+	// Without a Mistral key the LLM features are turned off rather than failing
+	if cfg.NoLLM {
+		fmt.Fprintf(w, "%-16s skipped (-no-llm)\n", "MISTRAL_API_KEY")
+	} else if _, err = getAPIKey("MISTRAL_API_KEY"); err != nil {
+		fmt.Fprintf(w, "%-16s missing, LLM features will be disabled\n", "MISTRAL_API_KEY")
+	} else {
+		report("MISTRAL_API_KEY", nil)
+	}
+
+	return ok
+}
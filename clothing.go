@@ -0,0 +1,60 @@
+package main
+
+import "strings"
+
+// Wind speed in m/s above which a windproof layer is suggested
+const windyThreshold = 8.0
+
+// Suggest what to wear for the current conditions, based on the feels-like
+// temperature, precipitation and wind. The result is deterministic so the same
+// advice is given with or without the LLM. There is no advice without a feels-like
+// temperature
+func clothingHint(data *WeatherData, units Units) string {
+	if data == nil || data.Main == nil || data.Main.FeelsLike == nil {
+		return ""
+	}
+
+	var hint string
+	switch feelsLike := convertTemp(*data.Main.FeelsLike, units, UnitsMetric); {
+	case feelsLike < 0:
+		hint = "a heavy coat, hat and gloves"
+	case feelsLike < 10:
+		hint = "a warm jacket"
+	case feelsLike < 18:
+		hint = "a light jacket or sweater"
+	case feelsLike < 25:
+		hint = "a t-shirt with a light layer for later"
+	default:
+		hint = "light, breathable clothes and sun protection"
+	}
+
+	var extras []string
+	if len(data.Weather) > 0 {
+		// OpenWeather condition codes: 2xx thunderstorm, 3xx drizzle, 5xx rain, 6xx snow
+		switch id := data.Weather[0].ID; {
+		case id >= 200 && id < 600:
+			extras = append(extras, "an umbrella or rain jacket")
+		case id >= 600 && id < 700:
+			extras = append(extras, "waterproof boots")
+		}
+	}
+	if len(extras) == 0 && data.Rain != nil && data.Rain.OneHour > 0 {
+		extras = append(extras, "an umbrella or rain jacket")
+	}
+	if data.Wind != nil && windSpeedMS(data.Wind.Speed, units) >= windyThreshold {
+		extras = append(extras, "a windproof layer")
+	}
+
+	if len(extras) > 0 {
+		hint += ", plus " + strings.Join(extras, " and ")
+	}
+	return "Wear " + hint + "."
+}
+
+// Convert a wind speed reported in the given unit system to m/s
+func windSpeedMS(speed float64, units Units) float64 {
+	if units == UnitsImperial {
+		return speed * 0.44704
+	}
+	return speed
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The same feel gives the same advice whatever the unit system of the reading
+func TestClothingHintUnits(t *testing.T) {
+	tests := []struct {
+		feelsLike float64
+		units     Units
+		want      string
+	}{
+		{-5, UnitsMetric, "a heavy coat"},
+		{23, UnitsImperial, "a heavy coat"},
+		{268.15, UnitsStandard, "a heavy coat"},
+		{15, UnitsMetric, "a light jacket"},
+		{59, UnitsImperial, "a light jacket"},
+		{288.15, UnitsStandard, "a light jacket"},
+		{30, UnitsMetric, "light, breathable clothes"},
+		{86, UnitsImperial, "light, breathable clothes"},
+	}
+	for _, tt := range tests {
+		data := &WeatherData{Main: &MainData{FeelsLike: floatPtr(tt.feelsLike)}}
+		if got := clothingHint(data, tt.units); !strings.HasPrefix(got, "Wear "+tt.want) {
+			t.Errorf("clothingHint(feels like %v, %s) = %q, want %q", tt.feelsLike, tt.units, got, "Wear "+tt.want+"...")
+		}
+	}
+}
+
+func TestClothingHintWithoutFeelsLike(t *testing.T) {
+	data := &WeatherData{Main: &MainData{Temp: floatPtr(12)}, Weather: []WeatherCondition{{ID: 500}}}
+	if got := clothingHint(data, UnitsMetric); got != "" {
+		t.Errorf("clothingHint without a feels-like temperature = %q, want no advice", got)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gage-technologies/mistral-go"
+	"golang.org/x/text/language"
+)
+
+// Default timeout for outbound HTTP requests
+const defaultHTTPTimeout = 10 * time.Second
+
+// Mistral models that can be selected with -model
+var knownModels = []string{
+	mistral.ModelOpenMistral7b,
+	mistral.ModelOpenMixtral8x7b,
+	mistral.ModelOpenMixtral8x22b,
+	mistral.ModelMistralSmallLatest,
+	mistral.ModelMistralMediumLatest,
+	mistral.ModelMistralLargeLatest,
+}
+
+// Config holds the settings gathered from command-line flags and the environment
+type Config struct {
+	Units        Units
+	Forecast     bool
+	HTTPTimeout  time.Duration
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	CacheTTL     time.Duration
+	CacheSize    int
+	NoCache      bool
+	Model        string
+	Serve        bool
+	Addr         string
+	Provider     string
+	JSON         bool
+	AQI          bool
+	RateLimit    int
+	RateBurst    int
+	Lang         string
+	NoLLM        bool
+	Quiet        bool
+	Verbose      bool
+	BaseURL      string
+	Check        bool
+	Precision    int
+	Temperature  float64
+	MaxTokens    int
+	ListModels   bool
+	Offline      bool
+	Batch        string
+	Concurrency  int
+	Once         bool
+	NoEmoji      bool
+	PersistCache bool
+	CacheDir     string
+	UserAgent    string
+	AutoLocation bool
+	GeoIPURL     string
+	Stream       bool
+	Days         int
+	ExtraUnits   []Units // shown after Units, with -units both or a list
+	Output       string
+	City         string
+	Locale       language.Tag // number formatting, separate from Lang
+	ClearCache   bool
+	NoNetwork    bool
+	Fields       []string // readings shown, in order
+
+	OutputTemplate *template.Template
+
+	// Question given as command-line arguments, and whether there were any. Without
+	// arguments the interactive prompt asks the questions; blank ones are no question
+	Question      string
+	QuestionGiven bool
+
+	ExtractPrompt  string
+	ResponsePrompt *template.Template
+
+	// Problems found while loading that are not worth failing for, logged once the
+	// logger is set up
+	Warnings []configWarning
+}
+
+// configWarning is a warning about the configuration, with its slog attributes
+type configWarning struct {
+	Msg  string
+	Args []any
+}
+
+// Parse command-line flags, falling back to environment variables for defaults
+func loadConfig() (*Config, error) {
+	flag.Usage = printUsage
+	units := flag.String("units", envOrDefault("WEATHER_UNITS", string(UnitsMetric)), "temperature units: metric, imperial or standard, a comma-separated list of them to show several, or both for metric and imperial")
+	forecast := flag.Bool("forecast", false, "summarize the 5-day forecast instead of current conditions")
+	noCache := flag.Bool("no-cache", false, "always fetch fresh weather and geocoding data instead of using the caches")
+	model := flag.String("model", envOrDefault("MISTRAL_MODEL", mistral.ModelOpenMistral7b), "Mistral model used for city extraction and answers")
+	serve := flag.Bool("serve", false, "run an HTTP server exposing POST /weather instead of the interactive prompt")
+	addr := flag.String("addr", ":8080", "listen address for -serve mode")
+	jsonOutput := flag.Bool("json", false, "print the weather data as JSON instead of an LLM-written answer")
+	aqi := flag.Bool("aqi", false, "include the air quality index and pollutant levels")
+	lang := flag.String("lang", envOrDefault("WEATHER_LANG", defaultLang), "language for weather descriptions and answers, e.g. fr, es, de")
+	noLLM := flag.Bool("no-llm", false, "skip Mistral and print the weather summary directly, extracting the city with a simple heuristic")
+	quiet := flag.Bool("quiet", false, "do not show a progress spinner while waiting for an answer")
+	verbose := flag.Bool("verbose", false, "print the extracted city, weather summary and model to stderr before the answer")
+	check := flag.Bool("check", false, "check the configuration and API keys without making any network calls, then exit")
+	precision := flag.Int("precision", defaultTempPrecision, "maximum number of decimals shown for temperatures, trailing zeros are dropped")
+	temperature := flag.String("temperature", envOrDefault("MISTRAL_TEMPERATURE", strconv.FormatFloat(mistral.DefaultChatRequestParams.Temperature, 'f', -1, 64)), "sampling temperature between 0 and 1 for the answer")
+	maxTokens := flag.String("max-tokens", envOrDefault("MISTRAL_MAX_TOKENS", strconv.Itoa(mistral.DefaultChatRequestParams.MaxTokens)), "maximum number of tokens in the answer")
+	outputTemplate := flag.String("template", defaultOutputTemplate, "Go template, or a file holding one, for the printed answer, e.g. \"{{.City}}: {{.Temp}}{{.Units}}\"")
+	listModels := flag.Bool("list-models", false, "list the Mistral models available to the API key, then exit")
+	timeout := flag.Duration("timeout", defaultTimeout, "total time allowed for answering one question, e.g. 5s or 1m")
+	oneCall := flag.Bool("onecall", false, "use the OpenWeather One Call 3.0 API, which needs a One Call subscription")
+	batch := flag.String("batch", "", "look up the weather for every city in `file`, one per line, then exit")
+	concurrency := flag.Int("concurrency", defaultConcurrency, "maximum number of weather API requests in flight at once, across -batch lookups and -serve requests")
+	once := flag.Bool("once", false, "answer a single question, from the arguments or the first line of stdin, then exit")
+	noEmoji := flag.Bool("no-emoji", false, "mark the conditions with plain text like [rain] instead of an emoji")
+	persistCache := flag.Bool("persist-cache", false, "keep cached weather and geocoding results on disk between runs")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "directory of the -persist-cache files")
+	autoLocation := flag.Bool("auto-location", false, "answer questions naming no city for this machine's approximate location, looked up by sending its IP address to an IP geolocation service")
+	noStream := flag.Bool("no-stream", false, "print interactive answers once complete instead of word by word as they are generated")
+	days := flag.Int("days", forecastDays, fmt.Sprintf("number of days summarized in -forecast mode, 1 to %d", forecastDays))
+	output := flag.String("output", "", "append answers to this file instead of printing them to stdout; logs stay on stderr")
+	city := flag.String("city", "", "answer about this city, e.g. \"Paris, FR\", instead of extracting one from the question")
+	locale := flag.String("locale", "", "locale of number formatting, e.g. de-DE for 20,5℃ (default from LC_ALL, LC_NUMERIC or LANG, else en-US)")
+	clearCache := flag.Bool("clear-cache", false, "delete the -persist-cache entries, only those about -city when it is set, and exit")
+	noNetwork := flag.Bool("no-network", false, "fail any request to a host other than localhost, including Mistral, e.g. to prove OFFLINE=1 makes no calls")
+	fields := flag.String("fields", "", "comma-separated readings to include in the summary and the LLM prompt, in the order given, out of "+strings.Join(fieldNames(), ", ")+" (default all of them)")
+	flag.Parse()
+
+	cfg := &Config{Forecast: *forecast, NoCache: *noCache, Serve: *serve, Addr: *addr, JSON: *jsonOutput, AQI: *aqi, Quiet: *quiet, Verbose: *verbose, Check: *check, ListModels: *listModels, Batch: *batch, Once: *once, NoEmoji: *noEmoji, PersistCache: *persistCache, CacheDir: *cacheDir, AutoLocation: *autoLocation, Output: *output, ClearCache: *clearCache, NoNetwork: *noNetwork}
+	cfg.City = normalizePlaceName(*city)
+	cfg.Question, cfg.QuestionGiven = questionFromArgs(flag.Args())
+
+	unitsList, err := parseUnitsList(*units)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Units, cfg.ExtraUnits = unitsList[0], unitsList[1:]
+
+	cfg.Fields, err = parseFields(*fields)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Model, err = parseModel(*model)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.NoLLM = *noLLM
+
+	cfg.Lang, err = parseLang(*lang)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prompt files are read once here so a bad path fails at startup
+	cfg.ExtractPrompt, err = loadExtractPrompt()
+	if err != nil {
+		return nil, err
+	}
+	var warnings []configWarning
+	cfg.ResponsePrompt, warnings, err = loadResponsePrompt()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Warnings = append(cfg.Warnings, warnings...)
+
+	cfg.Provider = strings.ToLower(envOrDefault("WEATHER_PROVIDER", ProviderOpenWeather))
+	if *oneCall {
+		cfg.Provider = ProviderOneCall
+	}
+
+	cfg.HTTPTimeout = defaultHTTPTimeout
+	if v := os.Getenv("WEATHER_HTTP_TIMEOUT"); v != "" {
+		cfg.HTTPTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEATHER_HTTP_TIMEOUT %q: %v", v, err)
+		}
+		if cfg.HTTPTimeout <= 0 {
+			return nil, fmt.Errorf("invalid WEATHER_HTTP_TIMEOUT %q: must be positive", v)
+		}
+	}
+
+	cfg.MaxRetries = defaultMaxRetries
+	if v := os.Getenv("WEATHER_MAX_RETRIES"); v != "" {
+		cfg.MaxRetries, err = strconv.Atoi(v)
+		if err != nil || cfg.MaxRetries < 0 {
+			return nil, fmt.Errorf("invalid WEATHER_MAX_RETRIES %q: must be a non-negative integer", v)
+		}
+	}
+
+	cfg.RetryBase, err = envDuration("WEATHER_RETRY_BASE", defaultRetryBaseDelay)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RetryMax, err = envDuration("WEATHER_RETRY_MAX", defaultRetryMaxDelay)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RetryMax < cfg.RetryBase {
+		return nil, fmt.Errorf("invalid WEATHER_RETRY_MAX %s: must be at least WEATHER_RETRY_BASE %s", cfg.RetryMax, cfg.RetryBase)
+	}
+
+	cfg.RateLimit, cfg.RateBurst, err = parseRateLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.CacheTTL = defaultCacheTTL
+	if v := os.Getenv("WEATHER_CACHE_TTL"); v != "" {
+		cfg.CacheTTL, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WEATHER_CACHE_TTL %q: %v", v, err)
+		}
+	}
+
+	if *precision < 0 || *precision > maxTempPrecision {
+		return nil, fmt.Errorf("invalid -precision %d: must be between 0 and %d", *precision, maxTempPrecision)
+	}
+	cfg.Precision = *precision
+
+	cfg.Temperature, err = strconv.ParseFloat(*temperature, 64)
+	if err != nil || cfg.Temperature < 0 || cfg.Temperature > 1 {
+		return nil, fmt.Errorf("invalid temperature %q: must be a number between 0 and 1", *temperature)
+	}
+	cfg.MaxTokens, err = strconv.Atoi(*maxTokens)
+	if err != nil || cfg.MaxTokens <= 0 {
+		return nil, fmt.Errorf("invalid max tokens %q: must be a positive integer", *maxTokens)
+	}
+
+	// Offline mode answers from canned data without any API keys, for demos
+	switch strings.ToLower(os.Getenv("OFFLINE")) {
+	case "1", "true", "yes":
+		cfg.Offline = true
+		cfg.NoLLM = true
+		cfg.Provider = ProviderOffline
+	}
+
+	cfg.Stream = !*noStream && *outputTemplate == defaultOutputTemplate
+	cfg.OutputTemplate, err = parseOutputTemplate(*outputTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -template: %w", err)
+	}
+
+	cfg.CacheSize = defaultCacheSize
+	if v := os.Getenv("WEATHER_CACHE_SIZE"); v != "" {
+		cfg.CacheSize, err = strconv.Atoi(v)
+		if err != nil || cfg.CacheSize <= 0 {
+			return nil, fmt.Errorf("invalid WEATHER_CACHE_SIZE %q: must be a positive integer", v)
+		}
+	}
+
+	if *timeout <= 0 {
+		return nil, fmt.Errorf("invalid -timeout %s: must be positive", *timeout)
+	}
+	cfg.Timeout = *timeout
+
+	if *concurrency < 1 {
+		return nil, fmt.Errorf("invalid -concurrency %d: must be at least 1", *concurrency)
+	}
+	cfg.Concurrency = *concurrency
+
+	// Too many days is clamped with a warning once logging is set up
+	if *days < 1 {
+		return nil, fmt.Errorf("invalid -days %d: must be at least 1", *days)
+	}
+	cfg.Days = *days
+
+	if (cfg.PersistCache || cfg.ClearCache) && cfg.CacheDir == "" {
+		return nil, fmt.Errorf("-persist-cache and -clear-cache need -cache-dir, as there is no default cache directory")
+	}
+
+	if cfg.AutoLocation && cfg.Serve {
+		return nil, fmt.Errorf("-auto-location can't be used with -serve, as it would locate the server rather than its clients")
+	}
+	cfg.GeoIPURL = strings.TrimSpace(envOrDefault("WEATHER_GEOIP_URL", defaultGeoIPURL))
+	if _, err := parseBaseURL(cfg.GeoIPURL); err != nil {
+		return nil, fmt.Errorf("invalid WEATHER_GEOIP_URL: %w", err)
+	}
+
+	cfg.Locale = systemLocale()
+	if *locale != "" {
+		cfg.Locale, err = parseLocale(*locale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -locale: %w", err)
+		}
+	}
+
+	if cfg.NoNetwork && cfg.ListModels {
+		return nil, fmt.Errorf("-list-models can't be used with -no-network, as it asks Mistral")
+	}
+
+	if *city != "" {
+		if err := validateCity(cfg.City); err != nil {
+			return nil, fmt.Errorf("invalid -city: %w", err)
+		}
+		if cfg.Batch != "" {
+			return nil, fmt.Errorf("-city can't be used with -batch, which looks up the cities in its file")
+		}
+	}
+
+	cfg.UserAgent = envOrDefault("WEATHER_USER_AGENT", defaultUserAgent())
+
+	cfg.BaseURL, err = parseBaseURL(envOrDefault("WEATHER_API_BASE_URL", defaultOpenWeatherBaseURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEATHER_API_BASE_URL: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Read a positive duration from the environment variable name, or def when it is unset
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", name, v, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, v)
+	}
+	return d, nil
+}
+
+// Check that an API base URL is an absolute http(s) URL and strip any trailing slash
+func parseBaseURL(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%q must be an absolute http or https URL", s)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%q must not have a query or fragment", s)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// Check the model name against the list of known Mistral models
+func parseModel(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, m := range knownModels {
+		if s == m {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown model %q: must be one of %s", s, strings.Join(knownModels, ", "))
+}
+
+// Return the value of an environment variable, or def if it is unset
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Join the command-line arguments into the question. given reports whether there were
+// any arguments, so a blank question such as "   " is told apart from none at all
+func questionFromArgs(args []string) (question string, given bool) {
+	return strings.TrimSpace(strings.Join(args, " ")), len(args) > 0
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestQuestionFromArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantQuestion string
+		wantGiven    bool
+	}{
+		{"no arguments", nil, "", false},
+		{"empty", []string{""}, "", true},
+		{"spaces only", []string{"   "}, "", true},
+		{"newline only", []string{"\n"}, "", true},
+		{"question", []string{"weather", "in", "Paris"}, "weather in Paris", true},
+		{"padded", []string{"  weather in Paris\n"}, "weather in Paris", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, given := questionFromArgs(tt.args)
+			if question != tt.wantQuestion || given != tt.wantGiven {
+				t.Errorf("questionFromArgs(%q) = %q, %v, want %q, %v", tt.args, question, given, tt.wantQuestion, tt.wantGiven)
+			}
+		})
+	}
+}
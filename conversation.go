@@ -0,0 +1,45 @@
+package main
+
+// Number of question/answer exchanges kept as context for follow-up questions
+const maxHistoryExchanges = 5
+
+// Conversation is the context carried from one question to the next, so follow-ups
+// like "what about tomorrow?" can be resolved
+type Conversation struct {
+	LastLocation Location
+	History      []Message
+	Usage        Usage // LLM tokens spent over the whole conversation
+}
+
+// Record a completed exchange, dropping the oldest ones beyond the history cap.
+// An empty answer only updates the last location
+func (c *Conversation) Record(loc Location, question, answer string) {
+	c.LastLocation = loc
+	if answer == "" {
+		return
+	}
+
+	c.History = append(c.History,
+		Message{Role: RoleUser, Content: question},
+		Message{Role: RoleAssistant, Content: answer},
+	)
+	if max := 2 * maxHistoryExchanges; len(c.History) > max {
+		c.History = append([]Message(nil), c.History[len(c.History)-max:]...)
+	}
+}
+
+// Location of the previous question, if any
+func (c *Conversation) lastLocation() Location {
+	if c == nil {
+		return Location{}
+	}
+	return c.LastLocation
+}
+
+// Earlier exchanges, oldest first
+func (c *Conversation) history() []Message {
+	if c == nil {
+		return nil
+	}
+	return c.History
+}
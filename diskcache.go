@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long to wait for another process to release a cache file lock
+const diskLockTimeout = 2 * time.Second
+
+// A lock file older than this was left behind by a crashed process and is ignored
+const diskLockStale = 10 * time.Second
+
+// diskStore keeps cache entries in a JSON file so they survive between runs. Writes
+// take a lock file, so concurrent invocations don't lose each other's entries
+type diskStore[V any] struct {
+	path string
+	ttl  time.Duration
+}
+
+type diskEntry[V any] struct {
+	Data    V         `json:"data"`
+	Expires time.Time `json:"expires"`
+}
+
+// Default directory of the persistent cache, under the user's cache directory
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "weather-assistant")
+}
+
+func newDiskStore[V any](path string, ttl time.Duration) *diskStore[V] {
+	return &diskStore[V]{path: path, ttl: ttl}
+}
+
+// Return the stored value for key and when it expires, if present and not expired
+func (s *diskStore[V]) Get(key string) (V, time.Time, bool) {
+	var zero V
+	entries, err := s.load()
+	if err != nil {
+		slog.Debug("could not read the cache file", "path", s.path, "error", err)
+		return zero, time.Time{}, false
+	}
+	entry, ok := entries[key]
+	if !ok || time.Now().After(entry.Expires) {
+		return zero, time.Time{}, false
+	}
+	return entry.Data, entry.Expires, true
+}
+
+// Store a value under key for the TTL, dropping expired entries while at it
+func (s *diskStore[V]) Set(key string, data V) {
+	if err := s.update(key, data); err != nil {
+		slog.Warn("could not write the cache file", "path", s.path, "error", err)
+	}
+}
+
+func (s *diskStore[V]) update(key string, data V) error {
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil {
+		// A corrupt file is replaced rather than blocking the cache forever
+		entries = make(map[string]diskEntry[V])
+	}
+	now := time.Now()
+	for k, e := range entries {
+		if now.After(e.Expires) {
+			delete(entries, k)
+		}
+	}
+	entries[key] = diskEntry[V]{Data: data, Expires: now.Add(s.ttl)}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a half-written cache
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Delete the entries match reports true for, or all of them when match is nil,
+// and return how many were removed. Expired entries are not counted
+func (s *diskStore[V]) Remove(match func(key string, data V) bool) (int, error) {
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := s.load()
+	if err != nil && match != nil {
+		return 0, err
+	}
+	removed := 0
+	now := time.Now()
+	for k, e := range entries {
+		if match == nil || match(k, e.Data) {
+			if !now.After(e.Expires) {
+				removed++
+			}
+			delete(entries, k)
+		}
+	}
+
+	if len(entries) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+		return removed, nil
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return 0, err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return 0, err
+	}
+	return removed, os.Rename(tmp, s.path)
+}
+
+// Delete the persistent cache entries, only those about city when it is not empty,
+// report how many were removed to w and return the exit code
+func clearCache(w io.Writer, dir, city string) int {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(w, "Nothing to clear, %s does not exist\n", dir)
+		return exitOK
+	}
+
+	weather := newDiskStore[*WeatherData](filepath.Join(dir, "weather.json"), 0)
+	geocode := newDiskStore[[]Location](filepath.Join(dir, "geocode.json"), 0)
+	pressure := newDiskStore[pressureSample](filepath.Join(dir, "pressure.json"), 0)
+
+	var matchWeather func(string, *WeatherData) bool
+	var matchGeocode func(string, []Location) bool
+	var matchPressure func(string, pressureSample) bool
+	if city != "" {
+		loc := parseQualifiedCity(city)
+		// Pressure samples share the location part of the weather keys, e.g. "48.8566,2.3522"
+		locationKeys := make(map[string]bool)
+		matchWeather = func(key string, data *WeatherData) bool {
+			if data == nil || !strings.EqualFold(data.Name, loc.Name) {
+				return false
+			}
+			locationKey, _, _ := strings.Cut(key, "|")
+			locationKeys[locationKey] = true
+			return true
+		}
+		matchPressure = func(key string, _ pressureSample) bool {
+			return locationKeys[key]
+		}
+		matchGeocode = func(key string, _ []Location) bool {
+			// Keys of qualified names, e.g. "paris,fr", start with the bare name
+			return key == loc.key() || loc.Country == "" && strings.HasPrefix(key, loc.key()+",")
+		}
+	}
+
+	nWeather, err := weather.Remove(matchWeather)
+	if err != nil {
+		fmt.Fprintln(w, "Error clearing the weather cache:", err)
+		return exitFailure
+	}
+	nGeocode, err := geocode.Remove(matchGeocode)
+	if err != nil {
+		fmt.Fprintln(w, "Error clearing the geocoding cache:", err)
+		return exitFailure
+	}
+	nPressure, err := pressure.Remove(matchPressure)
+	if err != nil {
+		fmt.Fprintln(w, "Error clearing the pressure samples:", err)
+		return exitFailure
+	}
+	fmt.Fprintf(w, "Removed %d weather, %d geocoding and %d pressure entries from %s\n", nWeather, nGeocode, nPressure, dir)
+	return exitOK
+}
+
+// Read all entries, treating a missing file as an empty cache
+func (s *diskStore[V]) load() (map[string]diskEntry[V], error) {
+	entries := make(map[string]diskEntry[V])
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Take an exclusive lock by creating path, waiting for other holders to finish.
+// It returns the function releasing the lock
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(diskLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > diskLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+// Map an OpenWeather condition code to an emoji, or "" for an unknown code
+func conditionEmoji(id int) string {
+	switch {
+	case id >= 200 && id < 300:
+		return "⛈️"
+	case id >= 300 && id < 400:
+		return "🌦️"
+	case id == 511:
+		// Freezing rain
+		return "🌨️"
+	case id >= 500 && id < 600:
+		return "🌧️"
+	case id >= 600 && id < 700:
+		return "❄️"
+	case id >= 700 && id < 800:
+		return "🌫️"
+	case id == 800:
+		return "☀️"
+	case id == 801:
+		return "🌤️"
+	case id == 802:
+		return "⛅"
+	case id == 803 || id == 804:
+		return "☁️"
+	default:
+		return ""
+	}
+}
+
+// Map an OpenWeather condition code to a plain-text marker, for terminals without emoji
+func conditionASCII(id int) string {
+	switch {
+	case id >= 200 && id < 300:
+		return "[storm]"
+	case id >= 300 && id < 400:
+		return "[drizzle]"
+	case id >= 500 && id < 600:
+		return "[rain]"
+	case id >= 600 && id < 700:
+		return "[snow]"
+	case id >= 700 && id < 800:
+		return "[fog]"
+	case id == 800:
+		return "[clear]"
+	case id > 800 && id < 900:
+		return "[clouds]"
+	default:
+		return ""
+	}
+}
+
+// Representative condition codes for the main groups, for providers that report no code
+var conditionGroupIDs = map[string]int{
+	"Thunderstorm": 200,
+	"Drizzle":      300,
+	"Rain":         500,
+	"Snow":         600,
+	"Fog":          741,
+	"Mist":         701,
+	"Clear":        800,
+	"Clouds":       803,
+}
+
+// Pick the icon for the current conditions, as emoji or, with ascii, a text marker
+func weatherIcon(data *WeatherData, ascii bool) string {
+	if data == nil || len(data.Weather) == 0 {
+		return ""
+	}
+	id := data.Weather[0].ID
+	if id == 0 {
+		id = conditionGroupIDs[data.Weather[0].Main]
+	}
+	if ascii {
+		return conditionASCII(id)
+	}
+	return conditionEmoji(id)
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestConditionEmoji(t *testing.T) {
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{200, "⛈️"}, {232, "⛈️"}, {299, "⛈️"},
+		{300, "🌦️"}, {321, "🌦️"},
+		{500, "🌧️"}, {504, "🌧️"}, {511, "🌨️"}, {520, "🌧️"}, {531, "🌧️"},
+		{600, "❄️"}, {622, "❄️"},
+		{701, "🌫️"}, {741, "🌫️"}, {781, "🌫️"},
+		{800, "☀️"},
+		{801, "🌤️"},
+		{802, "⛅"},
+		{803, "☁️"}, {804, "☁️"},
+		// Gaps and codes outside the groups have no emoji
+		{0, ""}, {199, ""}, {400, ""}, {450, ""}, {805, ""}, {900, ""}, {-1, ""},
+	}
+	for _, tt := range tests {
+		if got := conditionEmoji(tt.id); got != tt.want {
+			t.Errorf("conditionEmoji(%d) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestConditionASCII(t *testing.T) {
+	tests := []struct {
+		id   int
+		want string
+	}{
+		{211, "[storm]"}, {311, "[drizzle]"}, {511, "[rain]"}, {601, "[snow]"},
+		{741, "[fog]"}, {800, "[clear]"}, {801, "[clouds]"}, {804, "[clouds]"},
+		{0, ""}, {400, ""}, {900, ""},
+	}
+	for _, tt := range tests {
+		if got := conditionASCII(tt.id); got != tt.want {
+			t.Errorf("conditionASCII(%d) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherIcon(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  *WeatherData
+		ascii bool
+		want  string
+	}{
+		{"code", &WeatherData{Weather: []WeatherCondition{{ID: 500, Main: "Rain"}}}, false, "🌧️"},
+		{"code as text", &WeatherData{Weather: []WeatherCondition{{ID: 500, Main: "Rain"}}}, true, "[rain]"},
+		{"group without a code", &WeatherData{Weather: []WeatherCondition{{Main: "Snow"}}}, false, "❄️"},
+		{"unknown group", &WeatherData{Weather: []WeatherCondition{{Main: "Locusts"}}}, false, ""},
+		{"no conditions", &WeatherData{}, false, ""},
+		{"no data", nil, false, ""},
+	}
+	for _, tt := range tests {
+		if got := weatherIcon(tt.data, tt.ascii); got != tt.want {
+			t.Errorf("%s: weatherIcon = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
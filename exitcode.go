@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Process exit codes, so scripts can tell failures apart:
+//
+//	0  success
+//	1  unclassified failure, e.g. the server could not listen
+//	2  configuration error: bad flag or environment value, missing or rejected API key
+//	3  the city was not found or could not be extracted from the question
+//	4  the weather or LLM API failed or returned something unusable
+//	5  a request timed out
+const (
+	exitOK           = 0
+	exitFailure      = 1
+	exitConfig       = 2
+	exitCityNotFound = 3
+	exitUpstream     = 4
+	exitTimeout      = 5
+)
+
+// Map an error from answering a question to the exit code for its category
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		// Interrupted with Ctrl-C
+		return exitFailure
+	case errors.Is(err, ErrMissingAPIKey), errors.Is(err, ErrInvalidAPIKey), errors.Is(err, ErrNetworkDisabled),
+		errors.Is(err, ErrNoForecast):
+		return exitConfig
+	case errors.Is(err, ErrCityNotFound), errors.Is(err, ErrInvalidCity), errors.Is(err, ErrNoCity):
+		return exitCityNotFound
+	default:
+		// Everything else on the answer path comes from an upstream call
+		return exitUpstream
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// Usage the fake LLM reports for every call
+var fakeUsage = Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+
+// fakeLLM is an LLMClient answering without Mistral. Extraction requests, recognised by
+// the extraction prompt, get the quoted city named in the question or else the earlier
+// conversation, or NO_CITY; answer requests get the weather information from the end of
+// the system prompt. Setting complete replaces both
+type fakeLLM struct {
+	complete func(system, user string) (string, error)
+	calls    atomic.Int32
+}
+
+func (l *fakeLLM) Complete(ctx context.Context, params SamplingParams, system string, history []Message, user string) (string, Usage, error) {
+	l.calls.Add(1)
+	if err := ctx.Err(); err != nil {
+		return "", Usage{}, err
+	}
+	if l.complete != nil {
+		reply, err := l.complete(system, user)
+		return reply, fakeUsage, err
+	}
+	if system == defaultExtractPrompt {
+		// Like the model, fall back to the city of the earlier conversation
+		for _, text := range append([]string{user}, historyText(history)...) {
+			for _, city := range []string{"Paris", "London", "Tokyo"} {
+				if strings.Contains(text, city) {
+					return `"` + city + `"`, fakeUsage, nil
+				}
+			}
+		}
+		return noCityMarker, fakeUsage, nil
+	}
+	_, weatherInfo, _ := strings.Cut(system, "\n\n")
+	return "Answer: " + weatherInfo, fakeUsage, nil
+}
+
+// List the contents of history, latest first
+func historyText(history []Message) []string {
+	var texts []string
+	for i := len(history) - 1; i >= 0; i-- {
+		texts = append(texts, history[i].Content)
+	}
+	return texts
+}
+
+// fakeProvider is a WeatherProvider serving the current weather from fixtures by city
+// name, failing with ErrCityNotFound for any other
+type fakeProvider struct {
+	mu      sync.Mutex
+	weather map[string]*WeatherData
+	asked   []string // names of the locations asked about, in order
+}
+
+// Create a fakeProvider knowing Paris, London and Tokyo
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{weather: map[string]*WeatherData{
+		"Paris":  testWeather("Paris", 18.4, "broken clouds"),
+		"London": testWeather("London", 20, "light rain"),
+		"Tokyo":  testWeather("Tokyo", -2.5, "clear sky"),
+	}}
+}
+
+func (p *fakeProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.asked = append(p.asked, loc.Name)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data, ok := p.weather[loc.Name]
+	if !ok {
+		return nil, ErrCityNotFound
+	}
+	return data, nil
+}
+
+// Build the current weather of a city with just a temperature and conditions
+func testWeather(city string, temp float64, description string) *WeatherData {
+	return &WeatherData{
+		Name:    city,
+		Main:    &MainData{Temp: floatPtr(temp), FeelsLike: floatPtr(temp), Humidity: floatPtr(50)},
+		Weather: []WeatherCondition{{ID: 800, Main: "Clear", Description: description}},
+	}
+}
+
+// Build a config with the defaults of the flags that matter when answering questions
+func testConfig(t *testing.T) *Config {
+	t.Helper()
+	tmpl, err := template.New("response").Parse(defaultResponsePrompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Config{
+		Units:          UnitsMetric,
+		Timeout:        5 * time.Second,
+		Precision:      defaultTempPrecision,
+		Provider:       ProviderOpenMeteo,
+		Locale:         defaultLocale,
+		ExtractPrompt:  defaultExtractPrompt,
+		ResponsePrompt: tmpl,
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A reading of the current weather that -fields can select. phrase renders it for the
+// summary sentence and lines for the labeled LLM prompt, both with the given Formatter;
+// they return nothing when the reading is not reported
+type weatherField struct {
+	name   string
+	phrase func(data *WeatherData, f Formatter) string
+	lines  func(data *WeatherData, f Formatter) []string
+}
+
+// Every selectable reading, in the default order
+var weatherFields = []weatherField{
+	{"conditions",
+		func(d *WeatherData, _ Formatter) string { return d.description() },
+		func(d *WeatherData, _ Formatter) []string { return labeled("Conditions", d.description()) }},
+	{"temp",
+		func(d *WeatherData, f Formatter) string {
+			if d.Main == nil || d.Main.Temp == nil {
+				return ""
+			}
+			return "a temperature of " + f.Temp(*d.Main.Temp)
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if d.Main == nil || d.Main.Temp == nil {
+				return nil
+			}
+			return labeled("Temperature", f.Temp(*d.Main.Temp))
+		}},
+	{"feels_like",
+		func(d *WeatherData, f Formatter) string {
+			if d.Main == nil || d.Main.FeelsLike == nil {
+				return ""
+			}
+			return "feels like " + f.Temp(*d.Main.FeelsLike)
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if d.Main == nil || d.Main.FeelsLike == nil {
+				return nil
+			}
+			return labeled("Feels like", f.Temp(*d.Main.FeelsLike))
+		}},
+	{"humidity",
+		func(d *WeatherData, _ Formatter) string {
+			if d.Main == nil || d.Main.Humidity == nil {
+				return ""
+			}
+			return fmt.Sprintf("humidity %.0f%%", *d.Main.Humidity)
+		},
+		func(d *WeatherData, _ Formatter) []string {
+			if d.Main == nil || d.Main.Humidity == nil {
+				return nil
+			}
+			return labeled("Humidity", fmt.Sprintf("%.0f%%", *d.Main.Humidity))
+		}},
+	// By default the sentence only mentions pressure once there is a trend to go with it
+	{"pressure",
+		func(d *WeatherData, f Formatter) string {
+			if d.Main == nil || d.Main.Pressure == nil {
+				return ""
+			}
+			pressure := fmt.Sprintf("pressure %.0f hPa", *d.Main.Pressure)
+			switch {
+			case d.PressureTrend != "":
+				return pressure + " and " + d.PressureTrend
+			case f.Fields != nil:
+				return pressure
+			default:
+				return ""
+			}
+		},
+		func(d *WeatherData, _ Formatter) []string {
+			if d.Main == nil || d.Main.Pressure == nil {
+				return nil
+			}
+			pressure := fmt.Sprintf("%.0f hPa", *d.Main.Pressure)
+			if d.PressureTrend != "" {
+				pressure += ", " + d.PressureTrend + " since the last reading"
+			}
+			return labeled("Pressure", pressure)
+		}},
+	// The range collapses to nothing when min and max round to the same value
+	{"range",
+		func(d *WeatherData, f Formatter) string {
+			if low, high := tempRange(d, f); low != high {
+				return fmt.Sprintf("ranging from %s to %s today", low, high)
+			}
+			return ""
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if low, high := tempRange(d, f); low != high {
+				return labeled("Today's range", low+" to "+high)
+			}
+			return nil
+		}},
+	{"wind",
+		func(d *WeatherData, f Formatter) string {
+			if wind := windReading(d, f); wind != "" {
+				return "wind " + wind
+			}
+			return ""
+		},
+		func(d *WeatherData, f Formatter) []string { return labeled("Wind", windReading(d, f)) }},
+	{"clouds",
+		func(d *WeatherData, _ Formatter) string {
+			if d.Clouds == nil {
+				return ""
+			}
+			return fmt.Sprintf("cloud cover %.0f%%", d.Clouds.All)
+		},
+		func(d *WeatherData, _ Formatter) []string {
+			if d.Clouds == nil {
+				return nil
+			}
+			return labeled("Cloud cover", fmt.Sprintf("%.0f%%", d.Clouds.All))
+		}},
+	{"rain",
+		func(d *WeatherData, f Formatter) string {
+			if d.Rain == nil || d.Rain.OneHour <= 0 {
+				return ""
+			}
+			return "rain " + f.Decimal(d.Rain.OneHour, 1) + "mm in the last hour"
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if d.Rain == nil || d.Rain.OneHour <= 0 {
+				return nil
+			}
+			return labeled("Rain", f.Decimal(d.Rain.OneHour, 1)+"mm in the last hour")
+		}},
+	{"snow",
+		func(d *WeatherData, f Formatter) string {
+			if d.Snow == nil || d.Snow.OneHour <= 0 {
+				return ""
+			}
+			return "snow " + f.Decimal(d.Snow.OneHour, 1) + "mm in the last hour"
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if d.Snow == nil || d.Snow.OneHour <= 0 {
+				return nil
+			}
+			return labeled("Snow", f.Decimal(d.Snow.OneHour, 1)+"mm in the last hour")
+		}},
+	{"visibility",
+		func(d *WeatherData, f Formatter) string {
+			if d.Visibility == nil {
+				return ""
+			}
+			return "visibility " + f.Decimal(*d.Visibility/1000, 1) + " km"
+		},
+		func(d *WeatherData, f Formatter) []string {
+			if d.Visibility == nil {
+				return nil
+			}
+			return labeled("Visibility", f.Decimal(*d.Visibility/1000, 1)+" km")
+		}},
+	// Sunrise and sunset are reported in UTC, so shift them into the city's local time
+	{"sun",
+		func(d *WeatherData, _ Formatter) string {
+			if d.Sys == nil || d.Sys.Sunrise == 0 || d.Sys.Sunset == 0 {
+				return ""
+			}
+			return fmt.Sprintf("sunrise %s, sunset %s local time", formatLocalTime(d.Sys.Sunrise, d.Timezone), formatLocalTime(d.Sys.Sunset, d.Timezone))
+		},
+		func(d *WeatherData, _ Formatter) []string {
+			if d.Sys == nil || d.Sys.Sunrise == 0 || d.Sys.Sunset == 0 {
+				return nil
+			}
+			return []string{
+				"Sunrise: " + formatLocalTime(d.Sys.Sunrise, d.Timezone) + " local time",
+				"Sunset: " + formatLocalTime(d.Sys.Sunset, d.Timezone) + " local time",
+			}
+		}},
+	// The advice is a sentence of its own, so it always follows the summary sentence
+	{"advice",
+		func(*WeatherData, Formatter) string { return "" },
+		func(d *WeatherData, f Formatter) []string { return labeled("Advice", clothingHint(d, f.Units)) }},
+}
+
+// Names of every selectable reading, in the default order
+func fieldNames() []string {
+	names := make([]string, len(weatherFields))
+	for i, f := range weatherFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// Parse the -fields value, a comma-separated list of reading names. The order given is
+// the order they are shown in; an empty value selects the default, nil
+func parseFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	valid := fieldNames()
+	var fields []string
+	for _, part := range strings.Split(s, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(valid, name) {
+			return nil, fmt.Errorf("unknown field %q: must be one of %s", name, strings.Join(valid, ", "))
+		}
+		if !slices.Contains(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields given: must be some of %s", strings.Join(valid, ", "))
+	}
+	return fields, nil
+}
+
+// Look up the readings to show, in order: those in Fields, or all of them by default
+func (f Formatter) fields() []weatherField {
+	if f.Fields == nil {
+		return weatherFields
+	}
+	var fields []weatherField
+	for _, name := range f.Fields {
+		for _, field := range weatherFields {
+			if field.name == name {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// Format a prompt line, or nothing when the value is not reported
+func labeled(label, value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{label + ": " + value}
+}
+
+// Format today's low and high, both empty when either is not reported
+func tempRange(d *WeatherData, f Formatter) (low, high string) {
+	if m := d.Main; m != nil && m.TempMin != nil && m.TempMax != nil {
+		return f.Temp(*m.TempMin), f.Temp(*m.TempMax)
+	}
+	return "", ""
+}
+
+// Format the wind speed and direction, empty when wind is not reported
+func windReading(d *WeatherData, f Formatter) string {
+	if d.Wind == nil {
+		return ""
+	}
+	wind := f.Wind(d.Wind.Speed)
+	if d.Wind.Deg != nil {
+		wind += " from the " + degreesToCompass(*d.Wind.Deg)
+	}
+	return wind
+}
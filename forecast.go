@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Number of days summarized in forecast mode
+const forecastDays = 5
+
+// ForecastData mirrors the parts of the OpenWeather 5 day / 3 hour forecast response we use
+type ForecastData struct {
+	List []ForecastEntry `json:"list"`
+	City ForecastCity    `json:"city"`
+}
+
+// ForecastEntry is a single 3-hour interval of the forecast. Providers with only a
+// daily forecast report one entry per day, with the day's range in TempMin and TempMax
+type ForecastEntry struct {
+	Dt      int64              `json:"dt"`
+	Main    MainData           `json:"main"`
+	Weather []WeatherCondition `json:"weather"`
+}
+
+// ForecastCity describes the location the forecast is for
+type ForecastCity struct {
+	Name     string `json:"name"`
+	Coord    *Coord `json:"coord,omitempty"`
+	Country  string `json:"country"`
+	Timezone int    `json:"timezone"`
+}
+
+// DailyForecast summarizes the 3-hour entries falling on one local calendar day
+type DailyForecast struct {
+	Date      time.Time `json:"date"`
+	MinTemp   float64   `json:"min_temp"`
+	MaxTemp   float64   `json:"max_temp"`
+	Condition string    `json:"condition"`
+}
+
+// Fetch the 5 day / 3 hour forecast from OpenWeather API
+func fetchForecastData(ctx context.Context, loc Location, units Units, lang string) (*ForecastData, error) {
+	apiKey, err := getAPIKey("WEATHER_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/data/2.5/forecast?%s&appid=%s&units=%s&lang=%s", openWeatherBaseURL, loc.query(), apiKey, units, lang)
+
+	var forecastData ForecastData
+	if err := getJSON(ctx, url, &forecastData); err != nil {
+		return nil, err
+	}
+
+	if len(forecastData.List) == 0 {
+		return nil, fmt.Errorf("unexpected response format: forecast 'list' missing or empty")
+	}
+
+	return &forecastData, nil
+}
+
+// The city's timezone, as a fixed offset from UTC
+func (d *ForecastData) location() *time.Location {
+	return time.FixedZone(d.City.Name, d.City.Timezone)
+}
+
+// The date in the city at the given instant, as midnight local time
+func (d *ForecastData) localDate(now time.Time) time.Time {
+	t := now.In(d.location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Group the 3-hour entries by calendar day in the city's local timezone
+func dailyForecasts(data *ForecastData) []DailyForecast {
+	loc := data.location()
+
+	var days []DailyForecast
+	var counts []map[string]int
+	var hasTemp []bool
+	for _, entry := range data.List {
+		t := time.Unix(entry.Dt, 0).In(loc)
+		date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+		// Entries are ordered by time, so a new day always starts a new group
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			days = append(days, DailyForecast{Date: date})
+			counts = append(counts, map[string]int{})
+			hasTemp = append(hasTemp, false)
+		}
+
+		// Entries without a temperature only count towards the condition
+		day := &days[len(days)-1]
+		low, high := entry.Main.Temp, entry.Main.Temp
+		if low == nil {
+			low, high = entry.Main.TempMin, entry.Main.TempMax
+		}
+		if low != nil && high != nil {
+			if !hasTemp[len(days)-1] || *low < day.MinTemp {
+				day.MinTemp = *low
+			}
+			if !hasTemp[len(days)-1] || *high > day.MaxTemp {
+				day.MaxTemp = *high
+			}
+			hasTemp[len(days)-1] = true
+		}
+		if len(entry.Weather) > 0 {
+			counts[len(counts)-1][entry.Weather[0].Description]++
+		}
+	}
+
+	// The dominant condition is the one reported for the most intervals that day
+	for i := range days {
+		best := 0
+		for condition, n := range counts[i] {
+			if n > best || (n == best && condition < days[i].Condition) {
+				best = n
+				days[i].Condition = condition
+			}
+		}
+	}
+
+	if len(days) > forecastDays {
+		days = days[:forecastDays]
+	}
+	return days
+}
+
+// Format the first count days of the forecast, or all of them when count is 0, into a
+// human-readable day-by-day summary
+func formatForecastResponse(data *ForecastData, count int, f Formatter) (string, error) {
+	days := firstDays(dailyForecasts(data), count)
+	if len(days) == 0 {
+		return "", fmt.Errorf("unexpected response format: no forecast entries")
+	}
+	return formatDailyForecasts(fmt.Sprintf("The %d-day forecast for %s:", len(days), data.City.Name), days, f), nil
+}
+
+// Keep the first count days, or all of them when count is 0
+func firstDays(days []DailyForecast, count int) []DailyForecast {
+	if count > 0 && len(days) > count {
+		return days[:count]
+	}
+	return days
+}
+
+// Format the forecast for only the given days, as offsets from today, the city's local
+// date. Days are matched by date, as the forecast may start on the day after today
+func formatForecastDays(data *ForecastData, offsets []int, today time.Time, f Formatter) (string, error) {
+	all := dailyForecasts(data)
+
+	var days []DailyForecast
+	for _, o := range offsets {
+		date := today.AddDate(0, 0, o)
+		for _, day := range all {
+			if day.Date.Equal(date) {
+				days = append(days, day)
+			}
+		}
+	}
+	if len(days) == 0 {
+		return "", fmt.Errorf("%w: the forecast covers the next %d days", ErrBeyondForecast, forecastDays)
+	}
+	return formatDailyForecasts(fmt.Sprintf("The forecast for %s:", data.City.Name), days, f), nil
+}
+
+// Format a title followed by one line per day
+func formatDailyForecasts(title string, days []DailyForecast, f Formatter) string {
+	var sb strings.Builder
+	sb.WriteString(title)
+	for _, day := range days {
+		fmt.Fprintf(&sb, "\n%s: %s, low %s, high %s.", day.Date.Format("Mon Jan 2"), day.Condition, f.Temp(day.MinTemp), f.Temp(day.MaxTemp))
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Formatter turns readings into the text of answers. It carries the display settings
+// of the config, so formatting does not depend on state set at startup
+type Formatter struct {
+	Units      Units        // unit system the readings are in
+	ExtraUnits []Units      // further unit systems shown alongside Units
+	Precision  int          // maximum number of decimals shown for temperatures
+	Locale     language.Tag // decides the decimal and grouping separators
+	Fields     []string     // readings of the current weather shown, in order, nil for the default
+}
+
+// Create a Formatter for the unit system with the default display settings
+func newFormatter(units Units) Formatter {
+	return Formatter{Units: units, Precision: defaultTempPrecision}
+}
+
+// Formatter returns the Formatter for the configured display settings
+func (c *Config) Formatter() Formatter {
+	return Formatter{
+		Units:      c.Units,
+		ExtraUnits: c.ExtraUnits,
+		Precision:  c.Precision,
+		Locale:     c.Locale,
+		Fields:     c.Fields,
+	}
+}
+
+// Temp formats a temperature with its symbol, rounded to Precision decimals with
+// trailing zeros stripped, e.g. 20.0 -> "20℃" and 20.46 -> "20.5℃". Any ExtraUnits
+// follow, e.g. "20℃ / 68℉"
+func (f Formatter) Temp(t float64) string {
+	s := f.tempIn(f.Units, t)
+	for _, extra := range f.ExtraUnits {
+		if extra != f.Units {
+			s += " / " + f.tempIn(extra, convertTemp(t, f.Units, extra))
+		}
+	}
+	return s
+}
+
+// Format a temperature in the given unit system only
+func (f Formatter) tempIn(u Units, t float64) string {
+	// Rounding small negatives would otherwise leave "-0"
+	scale := math.Pow(10, float64(f.Precision))
+	if math.Round(t*scale) == 0 {
+		t = 0
+	}
+	return f.printer().Sprint(number.Decimal(t, number.MaxFractionDigits(f.Precision))) + u.Symbol()
+}
+
+// Wind formats a wind speed with its unit, followed by the speed in any ExtraUnits
+// with a different unit, e.g. "5.0 m/s / 11.2 mph"
+func (f Formatter) Wind(speed float64) string {
+	s := f.Decimal(speed, 1) + " " + f.Units.WindSymbol()
+	for _, extra := range f.ExtraUnits {
+		if extra.WindSymbol() != f.Units.WindSymbol() {
+			s += " / " + f.Decimal(convertWind(speed, f.Units, extra), 1) + " " + extra.WindSymbol()
+		}
+	}
+	return s
+}
+
+// Decimal formats v with exactly prec decimals, written the way the locale writes numbers
+func (f Formatter) Decimal(v float64, prec int) string {
+	return f.printer().Sprint(number.Decimal(v, number.Scale(prec)))
+}
+
+// Create a printer formatting numbers for the locale
+func (f Formatter) printer() *message.Printer {
+	return message.NewPrinter(f.Locale)
+}
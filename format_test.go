@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestFormatterTempRounding(t *testing.T) {
+	tests := []struct {
+		temp      float64
+		precision int
+		want      string
+	}{
+		{20, 1, "20℃"},
+		{20.0, 1, "20℃"},
+		{20.46, 1, "20.5℃"},
+		{20.44, 1, "20.4℃"},
+		{-3.25, 1, "-3.2℃"},
+		{-3.26, 1, "-3.3℃"},
+		// Exact halves round to even
+		{-12.5, 0, "-12℃"},
+		{-0.04, 1, "0℃"},
+		{-0.4, 0, "0℃"},
+		{-0.0001, 2, "0℃"},
+		{-0.05, 1, "-0.1℃"},
+		{0.04, 1, "0℃"},
+		{12.345, 2, "12.35℃"},
+		{12.3, 3, "12.3℃"},
+		{7.5, 0, "8℃"},
+	}
+	for _, tt := range tests {
+		f := Formatter{Units: UnitsMetric, Precision: tt.precision}
+		if got := f.Temp(tt.temp); got != tt.want {
+			t.Errorf("Temp(%v) with precision %d = %s, want %s", tt.temp, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestFormatterTempNegativeZero(t *testing.T) {
+	f := newFormatter(UnitsMetric)
+	if got := f.Temp(math.Copysign(0, -1)); got != "0℃" {
+		t.Errorf("Temp(-0) = %s, want 0℃", got)
+	}
+}
+
+func TestFormatterDualUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        Formatter
+		temp     float64
+		wind     float64
+		wantTemp string
+		wantWind string
+	}{
+		{"metric and imperial", Formatter{Units: UnitsMetric, ExtraUnits: []Units{UnitsImperial}, Precision: 1}, 20, 5, "20℃ / 68℉", "5.0 m/s / 11.2 mph"},
+		{"imperial and metric", Formatter{Units: UnitsImperial, ExtraUnits: []Units{UnitsMetric}, Precision: 1}, 68, 10, "68℉ / 20℃", "10.0 mph / 4.5 m/s"},
+		{"negative", Formatter{Units: UnitsMetric, ExtraUnits: []Units{UnitsImperial}, Precision: 1}, -18, 0, "-18℃ / -0.4℉", "0.0 m/s / 0.0 mph"},
+		{"all three", Formatter{Units: UnitsMetric, ExtraUnits: []Units{UnitsImperial, UnitsStandard}, Precision: 1}, 0, 1, "0℃ / 32℉ / 273.1K", "1.0 m/s / 2.2 mph"},
+		{"same wind unit shown once", Formatter{Units: UnitsMetric, ExtraUnits: []Units{UnitsStandard}, Precision: 0}, 21.6, 3, "22℃ / 295K", "3.0 m/s"},
+		{"the main unit is not repeated", Formatter{Units: UnitsMetric, ExtraUnits: []Units{UnitsMetric, UnitsImperial}, Precision: 1}, 10, 2, "10℃ / 50℉", "2.0 m/s / 4.5 mph"},
+		{"single unit", newFormatter(UnitsImperial), 50, 2, "50℉", "2.0 mph"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.Temp(tt.temp); got != tt.wantTemp {
+				t.Errorf("Temp(%v) = %s, want %s", tt.temp, got, tt.wantTemp)
+			}
+			if got := tt.f.Wind(tt.wind); got != tt.wantWind {
+				t.Errorf("Wind(%v) = %s, want %s", tt.wind, got, tt.wantWind)
+			}
+		})
+	}
+}
+
+func TestFormatterLocale(t *testing.T) {
+	tests := []struct {
+		locale   string
+		wantTemp string
+		wantWind string
+		wantBig  string
+	}{
+		{"en-US", "20.5℃", "3.6 m/s", "1,013.0"},
+		{"de-DE", "20,5℃", "3,6 m/s", "1.013,0"},
+		// French groups thousands with a no-break space
+		{"fr-FR", "20,5℃", "3,6 m/s", "1\u00a0013,0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			f := Formatter{Units: UnitsMetric, Precision: 1, Locale: language.MustParse(tt.locale)}
+			if got := f.Temp(20.46); got != tt.wantTemp {
+				t.Errorf("Temp(20.46) = %q, want %q", got, tt.wantTemp)
+			}
+			if got := f.Wind(3.6); got != tt.wantWind {
+				t.Errorf("Wind(3.6) = %q, want %q", got, tt.wantWind)
+			}
+			if got := f.Decimal(1013, 1); got != tt.wantBig {
+				t.Errorf("Decimal(1013, 1) = %q, want %q", got, tt.wantBig)
+			}
+		})
+	}
+}
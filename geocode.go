@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Maximum number of candidates requested from the geocoding API
+const geocodeLimit = 5
+
+// geocodeResult is a single entry of the OpenWeather Geo API direct geocoding response
+type geocodeResult struct {
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+}
+
+// Look up candidate locations matching a city name using the OpenWeather Geo API
+func geocodeCity(ctx context.Context, loc Location) ([]Location, error) {
+	if geocodeCache != nil {
+		if matches, ok := geocodeCache.Get(loc.key()); ok {
+			slog.DebugContext(ctx, "using cached geocoding result", "location", loc.String())
+			return matches, nil
+		}
+	}
+
+	apiKey, err := getAPIKey("WEATHER_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/geo/1.0/direct?%s&limit=%d&appid=%s", openWeatherBaseURL, loc.query(), geocodeLimit, apiKey)
+
+	var results []geocodeResult
+	if err := getJSON(ctx, url, &results); err != nil {
+		return nil, err
+	}
+
+	locations := make([]Location, 0, len(results))
+	for _, r := range results {
+		locations = append(locations, Location{
+			Name:      r.Name,
+			State:     r.State,
+			Country:   r.Country,
+			Lat:       r.Lat,
+			Lon:       r.Lon,
+			HasCoords: true,
+		})
+	}
+
+	if geocodeCache != nil {
+		geocodeCache.Set(loc.key(), locations)
+	}
+	return locations, nil
+}
+
+// Describe the places an ambiguous city name could refer to, or return an empty
+// string when the matches all name the same place
+func ambiguityNote(loc Location, matches []Location) string {
+	var options []string
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		option := strings.Join(strings.Split(m.qualifiedName(), ","), ", ")
+		if !seen[option] {
+			seen[option] = true
+			options = append(options, option)
+		}
+	}
+	if len(options) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("Note: %q matches several places (%s). Add a state or country to be more specific, e.g. \"%s\".",
+		loc.Name, strings.Join(options, "; "), options[0])
+}
+
+// Similarity below which the geocoded name may be a different place than the one asked about
+const minNameSimilarity = 0.75
+
+// Score how alike two place names are, from 0 for nothing in common to 1 for the same
+// name ignoring case, accents and spacing, as 1 minus the edit distance over the longer length
+func nameSimilarity(a, b string) float64 {
+	ra := []rune(foldPlaceName(a))
+	rb := []rune(foldPlaceName(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// Reduce a place name to the form it is compared in: normalized, lowercase and without
+// accents, so "São Paulo " and "sao paulo" are the same
+func foldPlaceName(s string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(stripAccents, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(normalizePlaceName(folded))
+}
+
+// Count the single-rune insertions, deletions and substitutions turning a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
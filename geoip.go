@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Default IP geolocation service used by -auto-location. Replacements must answer
+// with the same JSON fields
+const defaultGeoIPURL = "https://ipapi.co/json/"
+
+// IP geolocation service in use, set from the config at startup
+var geoIPURL = defaultGeoIPURL
+
+// geoIPResponse is the part of the ipapi.co response we use
+type geoIPResponse struct {
+	City        string  `json:"city"`
+	CountryCode string  `json:"country_code"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Error       bool    `json:"error"`
+	Reason      string  `json:"reason"`
+}
+
+// Find the approximate location of this machine from its public IP address. The
+// geolocation service sees the address, which is why -auto-location is opt-in
+func locateByIP(ctx context.Context) (Location, error) {
+	if offlineMode {
+		return Location{}, errors.New("IP geolocation is not available in offline mode")
+	}
+
+	var resp geoIPResponse
+	if err := getJSON(ctx, geoIPURL, &resp); err != nil {
+		return Location{}, fmt.Errorf("failed to look up location by IP address: %w", err)
+	}
+	if resp.Error {
+		return Location{}, fmt.Errorf("failed to look up location by IP address: %s", resp.Reason)
+	}
+	if resp.Latitude == 0 && resp.Longitude == 0 {
+		return Location{}, errors.New("failed to look up location by IP address: no coordinates in the response")
+	}
+	return Location{
+		Name:      resp.City,
+		Country:   resp.CountryCode,
+		Lat:       resp.Latitude,
+		Lon:       resp.Longitude,
+		HasCoords: true,
+	}, nil
+}
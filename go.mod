@@ -1,9 +1,21 @@
 module github.com/devgotech
 
-go 1.20
+go 1.21
 
 require (
 	github.com/gage-technologies/mistral-go v1.1.0
 	github.com/joho/godotenv v1.5.1
-	golang.org/x/net v0.28.0
+	github.com/prometheus/client_golang v1.19.1
+	golang.org/x/text v0.14.0
+	golang.org/x/time v0.5.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
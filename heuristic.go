@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// matches a capitalized place name following "in", "at" or "for", e.g. "weather in New York, US"
+var placeAfterPrepositionRe = regexp.MustCompile(`\b(?:[Ii]n|[Aa]t|[Ff]or)\s+(\p{Lu}[\p{L}'.-]*(?:,?\s+\p{Lu}[\p{L}'.-]*)*)`)
+
+// Extract the city from the user's input without an LLM. It looks for a capitalized
+// name after "in", "at" or "for", and otherwise accepts a short input as the name itself
+func extractCityHeuristic(userMessage string) (string, error) {
+	userMessage = strings.TrimSpace(userMessage)
+
+	if matches := placeAfterPrepositionRe.FindStringSubmatch(userMessage); matches != nil {
+		return strings.Trim(matches[1], " .,;:!?"), nil
+	}
+
+	if looksLikeBareCity(userMessage) {
+		return strings.Trim(userMessage, " .,;:!"), nil
+	}
+
+	return "", fmt.Errorf("%w: try e.g. \"weather in Paris\"", ErrNoCity)
+}
+
+// Longest input, in words, still taken to be just a place name
+const maxBareCityWords = 3
+
+// matches input made only of letters, spaces and the punctuation found in place names
+var bareCityRe = regexp.MustCompile(`^[\p{L}\s'.,-]+$`)
+
+// Words that show the input is a question or sentence rather than a place name
+var nonCityWords = map[string]bool{
+	"what": true, "how": true, "is": true, "will": true, "should": true, "do": true, "does": true,
+	"weather": true, "forecast": true, "temperature": true, "rain": true, "raining": true, "snow": true,
+	"sunny": true, "today": true, "tomorrow": true, "tonight": true, "there": true, "it": true, "and": true,
+	"the": true, "in": true, "at": true, "for": true, "hi": true, "hello": true, "thanks": true,
+}
+
+// Report whether the input is just a place name, e.g. "Tokyo" or "Paris, FR", so it
+// can be used as-is without asking the LLM to extract it
+func looksLikeBareCity(userMessage string) bool {
+	userMessage = strings.Trim(userMessage, " .!")
+	if userMessage == "" || !bareCityRe.MatchString(userMessage) {
+		return false
+	}
+
+	words := strings.FieldsFunc(userMessage, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(words) > maxBareCityWords {
+		return false
+	}
+	for _, w := range words {
+		if nonCityWords[strings.ToLower(w)] {
+			return false
+		}
+	}
+	return true
+}
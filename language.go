@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default language for weather descriptions and answers
+const defaultLang = "en"
+
+// Language codes accepted by the OpenWeather lang parameter, with the language name
+// used to instruct the LLM
+var supportedLanguages = map[string]string{
+	"af":    "Afrikaans",
+	"al":    "Albanian",
+	"ar":    "Arabic",
+	"az":    "Azerbaijani",
+	"bg":    "Bulgarian",
+	"ca":    "Catalan",
+	"cz":    "Czech",
+	"da":    "Danish",
+	"de":    "German",
+	"el":    "Greek",
+	"en":    "English",
+	"es":    "Spanish",
+	"eu":    "Basque",
+	"fa":    "Persian",
+	"fi":    "Finnish",
+	"fr":    "French",
+	"gl":    "Galician",
+	"he":    "Hebrew",
+	"hi":    "Hindi",
+	"hr":    "Croatian",
+	"hu":    "Hungarian",
+	"id":    "Indonesian",
+	"it":    "Italian",
+	"ja":    "Japanese",
+	"kr":    "Korean",
+	"la":    "Latvian",
+	"lt":    "Lithuanian",
+	"mk":    "Macedonian",
+	"nl":    "Dutch",
+	"no":    "Norwegian",
+	"pl":    "Polish",
+	"pt":    "Portuguese",
+	"pt_br": "Brazilian Portuguese",
+	"ro":    "Romanian",
+	"ru":    "Russian",
+	"se":    "Swedish",
+	"sk":    "Slovak",
+	"sl":    "Slovenian",
+	"sp":    "Spanish",
+	"sr":    "Serbian",
+	"sv":    "Swedish",
+	"th":    "Thai",
+	"tr":    "Turkish",
+	"ua":    "Ukrainian",
+	"uk":    "Ukrainian",
+	"vi":    "Vietnamese",
+	"zh_cn": "Simplified Chinese",
+	"zh_tw": "Traditional Chinese",
+	"zu":    "Zulu",
+}
+
+// Check a language code against the codes OpenWeather supports
+func parseLang(s string) (string, error) {
+	code := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := supportedLanguages[code]; ok {
+		return code, nil
+	}
+
+	codes := make([]string, 0, len(supportedLanguages))
+	for c := range supportedLanguages {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	return "", fmt.Errorf("unsupported language %q: must be one of %s", s, strings.Join(codes, ", "))
+}
+
+// Instruction appended to the system prompt so the LLM answers in the chosen language
+func languageInstruction(lang string) string {
+	if lang == "" || lang == defaultLang {
+		return ""
+	}
+	return fmt.Sprintf(" Always reply in %s.", supportedLanguages[lang])
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gage-technologies/mistral-go"
+)
+
+// LLMClient completes a prompt made of a system instruction, any earlier exchanges
+// and the new user message
+type LLMClient interface {
+	Complete(ctx context.Context, params SamplingParams, system string, history []Message, user string) (string, Usage, error)
+}
+
+// StreamingLLM is implemented by LLM clients that can deliver a completion as it is
+// generated. onToken receives each piece of text in order; the full text is returned too
+type StreamingLLM interface {
+	Stream(ctx context.Context, params SamplingParams, system string, history []Message, user string, onToken func(string)) (string, Usage, error)
+}
+
+// SamplingParams controls how the model generates a completion
+type SamplingParams struct {
+	Temperature float64
+	MaxTokens   int
+}
+
+// City extraction wants the same short answer every time
+var extractionParams = SamplingParams{Temperature: 0, MaxTokens: 50}
+
+// Sampling used to phrase the answer, from -temperature and -max-tokens
+func (c *Config) responseParams() SamplingParams {
+	return SamplingParams{Temperature: c.Temperature, MaxTokens: c.MaxTokens}
+}
+
+// Usage counts the tokens spent on one or more LLM calls
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add the tokens of another call to the running total
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// String formats the usage for verbose output
+func (u Usage) String() string {
+	return fmt.Sprintf("%d prompt + %d completion = %d tokens", u.PromptTokens, u.CompletionTokens, u.TotalTokens)
+}
+
+// Roles of the messages in a conversation history
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of an earlier exchange with the user
+type Message struct {
+	Role    string
+	Content string
+}
+
+var (
+	// ErrLLM is returned when a Mistral request fails
+	ErrLLM = errors.New("Mistral request failed")
+	// ErrLLMRateLimited is returned when Mistral keeps answering 429 Too Many Requests
+	ErrLLMRateLimited = errors.New("Mistral rate limit exceeded")
+	// ErrLLMEmptyResponse is returned when the model answers with no text at all
+	ErrLLMEmptyResponse = errors.New("LLM returned an empty response")
+)
+
+// MistralLLM is the default LLMClient, backed by the Mistral chat API
+type MistralLLM struct {
+	client *mistral.MistralClient
+	model  string
+}
+
+// Create a Mistral-backed LLM client using the given API key and model
+func NewMistralLLM(apiKey, model string) *MistralLLM {
+	return &MistralLLM{client: mistral.NewMistralClientDefault(apiKey), model: model}
+}
+
+// ListModels returns the IDs of the models the API key has access to.
+// Like Complete, the call is abandoned if ctx is done first
+func (m *MistralLLM) ListModels(ctx context.Context) ([]string, error) {
+	type result struct {
+		list *mistral.ModelList
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		list, err := m.client.ListModels()
+		done <- result{list, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		ids := make([]string, 0, len(r.list.Data))
+		for _, card := range r.list.Data {
+			ids = append(ids, card.ID)
+		}
+		sort.Strings(ids)
+		return ids, nil
+	}
+}
+
+// Warn when the configured model is not one the API key can use, so the problem
+// shows up at startup rather than on the first question
+func (m *MistralLLM) checkModel(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	ids, err := m.ListModels(ctx)
+	if err != nil {
+		slog.Debug("could not list Mistral models", "error", err)
+		return
+	}
+	for _, id := range ids {
+		if id == m.model {
+			return
+		}
+	}
+	slog.Warn("model is not available to this API key, see -list-models", "model", m.model)
+}
+
+// Complete sends the system prompt, history and user message to Mistral and returns the trimmed reply
+// with the tokens it used.
+// The Mistral client has no context support, so the call runs in a goroutine and is
+// abandoned if ctx is done first
+func (m *MistralLLM) Complete(ctx context.Context, sampling SamplingParams, system string, history []Message, user string) (string, Usage, error) {
+	type result struct {
+		resp *mistral.ChatCompletionResponse
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		resp, err := m.client.Chat(m.model, chatMessages(system, history, user), m.params(sampling))
+		done <- result{resp, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		//handle context cancellation, e.g., timeout
+		return "", Usage{}, contextError(ctx)
+	case r := <-done:
+		//proceed with processing the response
+		if r.err != nil {
+			return "", Usage{}, mistralError(r.err)
+		}
+
+		usage := Usage{
+			PromptTokens:     r.resp.Usage.PromptTokens,
+			CompletionTokens: r.resp.Usage.CompletionTokens,
+			TotalTokens:      r.resp.Usage.TotalTokens,
+		}
+		if len(r.resp.Choices) == 0 {
+			return "", usage, fmt.Errorf("%w: no response choices", ErrLLM)
+		}
+
+		content := strings.TrimSpace(r.resp.Choices[0].Message.Content)
+		if content == "" {
+			return "", usage, fmt.Errorf("%w: %w", ErrLLM, ErrLLMEmptyResponse)
+		}
+		return content, usage, nil
+	}
+}
+
+// Stream the completion of the conversation, passing each piece of text to onToken as
+// it arrives. Cancelling ctx stops delivering tokens and returns right away
+func (m *MistralLLM) Stream(ctx context.Context, sampling SamplingParams, system string, history []Message, user string, onToken func(string)) (string, Usage, error) {
+	type opened struct {
+		chunks <-chan mistral.ChatCompletionStreamResponse
+		err    error
+	}
+	done := make(chan opened, 1)
+	go func() {
+		chunks, err := m.client.ChatStream(m.model, chatMessages(system, history, user), m.params(sampling))
+		done <- opened{chunks, err}
+	}()
+
+	var chunks <-chan mistral.ChatCompletionStreamResponse
+	select {
+	case <-ctx.Done():
+		// The client has no context support, so the stream may still open after we give up on it
+		go func() {
+			if o := <-done; o.err == nil {
+				discardStream(o.chunks)
+			}
+		}()
+		return "", Usage{}, contextError(ctx)
+	case o := <-done:
+		if o.err != nil {
+			return "", Usage{}, mistralError(o.err)
+		}
+		chunks = o.chunks
+	}
+
+	var sb strings.Builder
+	var usage Usage
+	for {
+		select {
+		case <-ctx.Done():
+			go discardStream(chunks)
+			return sb.String(), usage, contextError(ctx)
+		case chunk, ok := <-chunks:
+			if !ok {
+				content := strings.TrimSpace(sb.String())
+				if content == "" {
+					return "", usage, fmt.Errorf("%w: %w", ErrLLM, ErrLLMEmptyResponse)
+				}
+				return content, usage, nil
+			}
+			if chunk.Error != nil {
+				go discardStream(chunks)
+				return "", usage, fmt.Errorf("%w: %v", ErrLLM, chunk.Error)
+			}
+			// Only the last chunk carries the token counts
+			if chunk.Usage.TotalTokens > 0 {
+				usage = Usage{
+					PromptTokens:     chunk.Usage.PromptTokens,
+					CompletionTokens: chunk.Usage.CompletionTokens,
+					TotalTokens:      chunk.Usage.TotalTokens,
+				}
+			}
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			token := chunk.Choices[0].Delta.Content
+			if sb.Len() == 0 {
+				// Match Complete, which trims the answer
+				token = strings.TrimLeft(token, " \n")
+			}
+			if token != "" {
+				sb.WriteString(token)
+				onToken(token)
+			}
+		}
+	}
+}
+
+// Read the rest of an abandoned stream, as the client blocks until it is consumed
+func discardStream(chunks <-chan mistral.ChatCompletionStreamResponse) {
+	for range chunks {
+	}
+}
+
+// Build the Mistral messages for a system prompt, earlier exchanges and the user's message
+func chatMessages(system string, history []Message, user string) []mistral.ChatMessage {
+	messages := []mistral.ChatMessage{
+		{
+			Role:    mistral.RoleSystem,
+			Content: system,
+		},
+	}
+	for _, msg := range history {
+		messages = append(messages, mistral.ChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return append(messages, mistral.ChatMessage{
+		Role:    mistral.RoleUser,
+		Content: user,
+	})
+}
+
+// Build the Mistral request parameters from the sampling settings
+func (m *MistralLLM) params(sampling SamplingParams) *mistral.ChatRequestParams {
+	params := mistral.DefaultChatRequestParams
+	params.Temperature = sampling.Temperature
+	params.MaxTokens = sampling.MaxTokens
+	return &params
+}
+
+// Describe why ctx ended the request
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("request timed out: %w", ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// Wrap an error from the Mistral client in ErrLLM, or ErrLLMRateLimited for a 429.
+// The client already retries 429s with backoff before giving up, and only reports
+// the status in the error text
+func mistralError(err error) error {
+	if strings.Contains(err.Error(), "(HTTP Error 429)") {
+		return fmt.Errorf("%w: %v", ErrLLMRateLimited, err)
+	}
+	return fmt.Errorf("%w: %v", ErrLLM, err)
+}
+
+// Call llm.Complete, asking a second time if the model answers with nothing.
+// Empty answers are usually a one-off glitch of the model, not a persistent failure
+func completeWithRetry(ctx context.Context, llm LLMClient, params SamplingParams, system string, history []Message, user string) (string, Usage, error) {
+	text, usage, err := llm.Complete(ctx, params, system, history, user)
+	if errors.Is(err, ErrLLMEmptyResponse) {
+		slog.WarnContext(ctx, "LLM returned an empty response, retrying once")
+		var retryUsage Usage
+		text, retryUsage, err = llm.Complete(ctx, params, system, history, user)
+		usage.Add(retryUsage)
+	}
+	return text, usage, err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gage-technologies/mistral-go"
+)
+
+// Create a MistralLLM talking to a mock chat API that replies with content
+func newMockMistral(t *testing.T, content string) *MistralLLM {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"test","choices":[{"index":0,"message":{"role":"assistant","content":"` + content + `"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":0,"total_tokens":12}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return &MistralLLM{client: mistral.NewMistralClient("test-key", srv.URL, 1, time.Second), model: "test"}
+}
+
+func TestMistralCompleteEmptyContent(t *testing.T) {
+	for _, content := range []string{"", "   ", `\n\t`} {
+		llm := newMockMistral(t, content)
+		_, usage, err := llm.Complete(context.Background(), extractionParams, "system", nil, "weather in Paris?")
+		if !errors.Is(err, ErrLLMEmptyResponse) || !errors.Is(err, ErrLLM) {
+			t.Errorf("Complete with content %q = %v, want %v", content, err, ErrLLMEmptyResponse)
+		}
+		if usage.TotalTokens != 12 {
+			t.Errorf("Complete with content %q used %d tokens, want the 12 reported", content, usage.TotalTokens)
+		}
+	}
+
+	llm := newMockMistral(t, `  \"Paris\" `)
+	if text, _, err := llm.Complete(context.Background(), extractionParams, "system", nil, "weather in Paris?"); err != nil || text != `"Paris"` {
+		t.Errorf("Complete = %q, %v, want the trimmed reply", text, err)
+	}
+}
+
+func TestCompleteWithRetry(t *testing.T) {
+	empty := func() (string, error) { return "", errors.Join(ErrLLM, ErrLLMEmptyResponse) }
+	reply := func() (string, error) { return `"Paris"`, nil }
+	failed := func() (string, error) { return "", ErrLLMRateLimited }
+
+	tests := []struct {
+		name      string
+		replies   []func() (string, error)
+		want      string
+		wantErr   error
+		wantCalls int
+	}{
+		{"first reply", []func() (string, error){reply}, `"Paris"`, nil, 1},
+		{"empty, then a reply", []func() (string, error){empty, reply}, `"Paris"`, nil, 2},
+		{"empty twice", []func() (string, error){empty, empty}, "", ErrLLMEmptyResponse, 2},
+		{"other errors are not retried", []func() (string, error){failed}, "", ErrLLMRateLimited, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeLLM{}
+			llm.complete = func(system, user string) (string, error) {
+				return tt.replies[int(llm.calls.Load())-1]()
+			}
+			text, usage, err := completeWithRetry(context.Background(), llm, extractionParams, "system", nil, "weather in Paris?")
+			if text != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("completeWithRetry = %q, %v, want %q, %v", text, err, tt.want, tt.wantErr)
+			}
+			if calls := int(llm.calls.Load()); calls != tt.wantCalls {
+				t.Errorf("%d calls, want %d", calls, tt.wantCalls)
+			}
+			// The tokens of the retry count too
+			if want := tt.wantCalls * fakeUsage.TotalTokens; usage.TotalTokens != want {
+				t.Errorf("usage = %d tokens, want %d", usage.TotalTokens, want)
+			}
+		})
+	}
+}
+
+func TestExtractCityEmptyResponse(t *testing.T) {
+	llm := &fakeLLM{complete: func(system, user string) (string, error) { return "", errors.Join(ErrLLM, ErrLLMEmptyResponse) }}
+	_, _, err := extractCityFromUserInput(context.Background(), llm, defaultExtractPrompt, nil, "is it sunny in Paris?")
+	if !errors.Is(err, ErrLLMEmptyResponse) {
+		t.Errorf("extractCityFromUserInput = %v, want %v", err, ErrLLMEmptyResponse)
+	}
+	if calls := llm.calls.Load(); calls != 2 {
+		t.Errorf("%d calls, want one retry", calls)
+	}
+}
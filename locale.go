@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Locale used when neither -locale nor the environment names one
+var defaultLocale = language.AmericanEnglish
+
+// Parse a -locale value, a BCP 47 tag such as de-DE or the POSIX form de_DE.UTF-8
+func parseLocale(s string) (language.Tag, error) {
+	name := strings.TrimSpace(s)
+	// A POSIX locale may carry a codeset and modifier, e.g. de_DE.UTF-8@euro
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	tag, err := language.Parse(name)
+	if err != nil {
+		return language.Und, fmt.Errorf("unknown locale %q: expected a form like en-US or de_DE.UTF-8", s)
+	}
+	return tag, nil
+}
+
+// Work out the system locale from the usual POSIX environment variables, falling
+// back to en-US for the C locale or when none is set
+func systemLocale() language.Tag {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" {
+			continue
+		}
+		if tag, err := parseLocale(v); err == nil {
+			return tag
+		}
+		// C, POSIX or something unparseable; the earlier variables override the later ones
+		break
+	}
+	return defaultLocale
+}
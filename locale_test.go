@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    language.Tag
+		wantErr bool
+	}{
+		{"en-US", language.AmericanEnglish, false},
+		{"de-DE", language.MustParse("de-DE"), false},
+		{"de_DE.UTF-8", language.MustParse("de-DE"), false},
+		{"fr_FR.UTF-8@euro", language.MustParse("fr-FR"), false},
+		{" pt-BR ", language.BrazilianPortuguese, false},
+		{"", language.Und, true},
+		{"not a locale", language.Und, true},
+	}
+	for _, tt := range tests {
+		got, err := parseLocale(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLocale(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLocale(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSystemLocale(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lcAll, lcNumeric, lang string
+		want                   language.Tag
+	}{
+		{"nothing set", "", "", "", defaultLocale},
+		{"LANG", "", "", "de_DE.UTF-8", language.MustParse("de-DE")},
+		{"LC_NUMERIC overrides LANG", "", "fr_FR.UTF-8", "de_DE.UTF-8", language.MustParse("fr-FR")},
+		{"LC_ALL overrides both", "en_GB.UTF-8", "fr_FR.UTF-8", "de_DE.UTF-8", language.BritishEnglish},
+		{"C locale", "C", "", "de_DE.UTF-8", defaultLocale},
+		{"POSIX locale", "", "POSIX", "de_DE.UTF-8", defaultLocale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LC_ALL", tt.lcAll)
+			t.Setenv("LC_NUMERIC", tt.lcNumeric)
+			t.Setenv("LANG", tt.lang)
+			if got := systemLocale(); got != tt.want {
+				t.Errorf("systemLocale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
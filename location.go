@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Location identifies where to fetch weather for, either by name, by postal code
+// (Zip together with Country) or by coordinates
+type Location struct {
+	Name      string
+	State     string
+	Country   string
+	Zip       string
+	Lat       float64
+	Lon       float64
+	HasCoords bool
+}
+
+// matches a "lat,lon" pair such as 48.85,2.35 or -33.9, 151.2, standing on its own
+// rather than being part of a longer number
+var coordinatesRe = regexp.MustCompile(`(?:^|[^\w.-])(-?\d{1,3}(?:\.\d+)?)\s*,\s*(-?\d{1,3}(?:\.\d+)?)(?:$|[^\w.,])`)
+
+// matches a word right before a pair of numbers saying that they are coordinates
+var coordinatesContextRe = regexp.MustCompile(`(?i)\b(?:at|lat|lat/lon|latlon|coordinates|coords|position)\s*:?\s*$`)
+
+// matches a five-digit postal code such as 94103 or 10115, or a US ZIP+4 such as 94103-1234
+var fiveDigitPostcodeRe = regexp.MustCompile(`\b(\d{5})(-\d{4})?\b`)
+
+// Countries with five-digit postal codes, by lowercase name and by ISO 3166 code
+var fiveDigitPostcodeCountries = map[string]string{
+	"us": "US", "usa": "US", "united states": "US", "america": "US",
+	"de": "DE", "germany": "DE", "deutschland": "DE",
+	"fr": "FR", "france": "FR",
+	"es": "ES", "spain": "ES", "españa": "ES",
+	"it": "IT", "italy": "IT", "italia": "IT",
+	"mx": "MX", "mexico": "MX", "méxico": "MX",
+	"fi": "FI", "finland": "FI",
+	"hr": "HR", "croatia": "HR",
+	"tr": "TR", "turkey": "TR", "türkiye": "TR",
+	"my": "MY", "malaysia": "MY",
+	"th": "TH", "thailand": "TH",
+}
+
+// US state and territory codes, which come right before a ZIP code as in "CA 94103"
+var usStateCodes = strings.Fields("AL AK AZ AR CA CO CT DE DC FL GA HI ID IL IN IA KS KY LA ME MD MA MI MN MS MO MT NE NV NH NJ NM NY NC ND OH OK OR PA RI SC SD TN TX UT VT VA WA WV WI WY PR GU VI")
+
+// matches a UK postcode such as SW1A 1AA or m1 1ae
+var ukPostcodeRe = regexp.MustCompile(`(?i)\b([A-Z]{1,2}\d[A-Z\d]?)\s*(\d[A-Z]{2})\b`)
+
+// Human-readable description of the location
+func (l Location) String() string {
+	if l.HasCoords && l.Name == "" {
+		return fmt.Sprintf("%.4f,%.4f", l.Lat, l.Lon)
+	}
+	if l.Zip != "" && l.Name == "" {
+		return l.Zip + ", " + l.Country
+	}
+	return l.qualifiedName()
+}
+
+// Join the city with any state and country qualifiers, as "city,state,country"
+func (l Location) qualifiedName() string {
+	parts := []string{strings.TrimSpace(l.Name)}
+	if l.State != "" {
+		parts = append(parts, l.State)
+	}
+	if l.Country != "" {
+		parts = append(parts, l.Country)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Normalized identifier for the location, used for cache lookups
+func (l Location) key() string {
+	if l.HasCoords {
+		return fmt.Sprintf("%.4f,%.4f", l.Lat, l.Lon)
+	}
+	if l.Zip != "" {
+		return strings.ToLower("zip:" + l.Zip + "," + l.Country)
+	}
+	return strings.ToLower(strings.Join(strings.Fields(l.qualifiedName()), " "))
+}
+
+// Build the OpenWeather query parameters selecting this location
+func (l Location) query() string {
+	if l.HasCoords {
+		return fmt.Sprintf("lat=%s&lon=%s", strconv.FormatFloat(l.Lat, 'f', -1, 64), strconv.FormatFloat(l.Lon, 'f', -1, 64))
+	}
+	if l.Zip != "" {
+		return "zip=" + url.QueryEscape(l.Zip+","+l.Country)
+	}
+	// URL-encode the city name to ensure it is safe for inclusion in a URL
+	return "q=" + url.QueryEscape(l.qualifiedName())
+}
+
+// Split an extracted "City, State, Country" string into a Location.
+// Two parts are read as city and country, three as city, state and country
+func parseQualifiedCity(s string) Location {
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		if p = normalizePlaceName(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return Location{}
+	case 1:
+		return Location{Name: displayCityName(parts[0])}
+	case 2:
+		return Location{Name: displayCityName(parts[0]), Country: strings.ToUpper(parts[1])}
+	default:
+		return Location{Name: displayCityName(parts[0]), State: strings.ToUpper(parts[1]), Country: strings.ToUpper(parts[2])}
+	}
+}
+
+// Clean up one part of a place name: collapse internal whitespace and strip
+// trailing punctuation such as a period or question mark
+func normalizePlaceName(s string) string {
+	s = strings.TrimRightFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r) && r != ')'
+	})
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Title-case a city name typed entirely in lowercase, e.g. "são paulo" -> "São Paulo".
+// Names with any capitals are kept as given, so "McAllen" or "dePaul" survive
+func displayCityName(name string) string {
+	if name != strings.ToLower(name) {
+		return name
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if i == 0 || runes[i-1] == ' ' || runes[i-1] == '-' {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+	return string(runes)
+}
+
+// Look for a latitude/longitude pair in the user's input. Whole numbers such as the
+// "2, 3" of "the next 2, 3 days" only count as coordinates after a word like "at"
+func parseCoordinates(input string) (Location, bool, error) {
+	var matches []string
+	for _, m := range coordinatesRe.FindAllStringSubmatchIndex(input, -1) {
+		lat, lon := input[m[2]:m[3]], input[m[4]:m[5]]
+		if strings.Contains(lat, ".") && strings.Contains(lon, ".") || coordinatesContextRe.MatchString(input[:m[2]]) {
+			matches = []string{input[m[0]:m[1]], lat, lon}
+			break
+		}
+	}
+	if matches == nil {
+		return Location{}, false, nil
+	}
+
+	lat, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return Location{}, false, err
+	}
+	lon, err := strconv.ParseFloat(matches[2], 64)
+	if err != nil {
+		return Location{}, false, err
+	}
+
+	if lat < -90 || lat > 90 {
+		return Location{}, true, fmt.Errorf("latitude %v out of range [-90, 90]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return Location{}, true, fmt.Errorf("longitude %v out of range [-180, 180]", lon)
+	}
+
+	return Location{Lat: lat, Lon: lon, HasCoords: true}, true, nil
+}
+
+// Look for a postal code in the user's input. UK postcodes and US ZIP+4 codes are
+// recognised by their shape. Five-digit codes are used in many countries, so one only
+// counts when the input names its country, or a US state right before it. Without
+// that, the number is left for city extraction to make sense of
+func parsePostalCode(input string) (Location, bool) {
+	if m := ukPostcodeRe.FindStringSubmatch(input); m != nil {
+		return Location{Zip: strings.ToUpper(m[1] + " " + m[2]), Country: "GB"}, true
+	}
+	m := fiveDigitPostcodeRe.FindStringSubmatchIndex(input)
+	if m == nil {
+		return Location{}, false
+	}
+	zip := input[m[2]:m[3]]
+	if m[4] >= 0 {
+		return Location{Zip: zip, Country: "US"}, true
+	}
+	if country := postcodeCountry(input[:m[0]], input[m[1]:]); country != "" {
+		return Location{Zip: zip, Country: country}, true
+	}
+	return Location{}, false
+}
+
+// Work out the country of a five-digit postal code from the input before and after it:
+// a US state code right before it, a country code right after it, or a country name
+// anywhere. It returns "" when there is no such hint
+func postcodeCountry(before, after string) string {
+	if words := strings.Fields(before); len(words) > 0 && slices.Contains(usStateCodes, strings.Trim(words[len(words)-1], ",")) {
+		return "US"
+	}
+	// Codes are only trusted in capitals, so the "it" in "is it cold" is not Italy
+	if words := strings.FieldsFunc(after, isWordSeparator); len(words) > 0 && len(words[0]) == 2 && words[0] == strings.ToUpper(words[0]) {
+		if country, ok := fiveDigitPostcodeCountries[strings.ToLower(words[0])]; ok {
+			return country
+		}
+	}
+	// The first name mentioned wins, should there be several
+	text := " " + strings.Join(strings.FieldsFunc(strings.ToLower(before+" "+after), isWordSeparator), " ") + " "
+	found, first := "", len(text)
+	for name, country := range fiveDigitPostcodeCountries {
+		if i := strings.Index(text, " "+name+" "); len(name) > 2 && i >= 0 && i < first {
+			found, first = country, i
+		}
+	}
+	return found
+}
+
+// Report whether r separates words, such as a space or punctuation
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// ExtractionMethod records how the location was found in the question
+type ExtractionMethod string
+
+const (
+	MethodCoordinates ExtractionMethod = "coordinates"
+	MethodPostalCode  ExtractionMethod = "postal_code"
+	MethodBareCity    ExtractionMethod = "bare_city"
+	MethodHeuristic   ExtractionMethod = "heuristic"
+	MethodLLM         ExtractionMethod = "llm"
+	MethodPrevious    ExtractionMethod = "previous" // reused from an earlier question
+	MethodIP          ExtractionMethod = "ip"       // located by IP address, with -auto-location
+	MethodFlag        ExtractionMethod = "flag"     // given with -city, skipping extraction
+	MethodBatch       ExtractionMethod = "batch"    // a line of the -batch file, skipping extraction
+)
+
+// Read input that is only a location, such as a -batch line: coordinates, a postal code
+// or a "City, State, Country" name, without asking the LLM or guessing from a sentence
+func parseLocation(input string) (Location, error) {
+	loc, found, err := parseCoordinates(input)
+	if err != nil || found {
+		return loc, err
+	}
+	if loc, found := parsePostalCode(input); found {
+		return loc, nil
+	}
+	city := normalizePlaceName(input)
+	if err := validateCity(city); err != nil {
+		return Location{}, err
+	}
+	return parseQualifiedCity(city), nil
+}
+
+// Work out which location the user is asking about. Coordinates and postal codes are used directly,
+// anything else goes through LLM city extraction with the given prompt, or a simple heuristic
+// when llm is nil
+func extractLocation(ctx context.Context, llm LLMClient, prompt string, history []Message, userMessage string) (Location, ExtractionMethod, Usage, error) {
+	loc, found, err := parseCoordinates(userMessage)
+	if err != nil {
+		return Location{}, MethodCoordinates, Usage{}, err
+	}
+	if found {
+		return loc, MethodCoordinates, Usage{}, nil
+	}
+	if loc, found := parsePostalCode(userMessage); found {
+		return loc, MethodPostalCode, Usage{}, nil
+	}
+
+	var city string
+	var method ExtractionMethod
+	var usage Usage
+	switch {
+	case looksLikeBareCity(userMessage):
+		// Nothing for the LLM to extract, save the round trip
+		slog.DebugContext(ctx, "input is a bare city, skipping LLM extraction", "input", userMessage)
+		city, method = strings.Trim(userMessage, " .!"), MethodBareCity
+	case llm == nil:
+		method = MethodHeuristic
+		city, err = extractCityHeuristic(userMessage)
+	default:
+		method = MethodLLM
+		city, usage, err = extractCityFromUserInput(ctx, llm, prompt, history, userMessage)
+	}
+	if err == nil {
+		// Catch junk from a misbehaving model before it turns into a confusing 404
+		err = validateCity(city)
+	}
+	if err != nil {
+		// A five-digit postal code without its country is likely what the user meant
+		if zip := fiveDigitPostcodeRe.FindString(userMessage); zip != "" && (errors.Is(err, ErrNoCity) || errors.Is(err, ErrInvalidCity)) {
+			return Location{}, MethodPostalCode, usage, fmt.Errorf("%w: %s", ErrPostcodeCountry, zip)
+		}
+		return Location{}, method, usage, err
+	}
+	return parseQualifiedCity(city), method, usage, nil
+}
+
+// ErrPostcodeCountry is returned for a five-digit postal code whose country the input
+// does not name, as such codes are used in many countries. It is an ErrInvalidCity
+var ErrPostcodeCountry = fmt.Errorf("%w: it is a postal code without a country", ErrInvalidCity)
+
+// Longest accepted city string, generous enough for real place names with qualifiers
+const maxCityLength = 100
+
+// ErrInvalidCity is returned when the extracted city is clearly not a place name
+var ErrInvalidCity = errors.New("that doesn't look like a city name")
+
+// Reject strings that are obviously not a city: empty, overly long, URLs or bare numbers
+func validateCity(city string) error {
+	city = strings.TrimSpace(city)
+	switch {
+	case city == "":
+		return fmt.Errorf("%w: it is empty", ErrInvalidCity)
+	case utf8.RuneCountInString(city) > maxCityLength:
+		return fmt.Errorf("%w: it is longer than %d characters", ErrInvalidCity, maxCityLength)
+	case strings.Contains(city, "://") || strings.HasPrefix(strings.ToLower(city), "www."):
+		return fmt.Errorf("%w: it is a URL", ErrInvalidCity)
+	case strings.Trim(city, "0123456789 .,-+") == "":
+		return fmt.Errorf("%w: it contains only digits", ErrInvalidCity)
+	}
+	return nil
+}
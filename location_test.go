@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateCity(t *testing.T) {
+	tests := []struct {
+		city    string
+		wantErr bool
+	}{
+		{"Paris", false},
+		{"São Paulo, BR", false},
+		{"Washington, DC, US", false},
+		{"Ho Chi Minh City", false},
+		{"Saint-Louis-du-Ha! Ha!", false},
+		{"Winston-Salem, NC, US", false},
+		{strings.Repeat("a", maxCityLength), false},
+
+		{"", true},
+		{"   ", true},
+		{strings.Repeat("a", maxCityLength+1), true},
+		{"https://example.com", true},
+		{"ftp://example.com/paris", true},
+		{"www.paris.fr", true},
+		{"12345", true},
+		{"48.85, 2.35", true},
+		{"-33.9", true},
+	}
+	for _, tt := range tests {
+		err := validateCity(tt.city)
+		if tt.wantErr && !errors.Is(err, ErrInvalidCity) {
+			t.Errorf("validateCity(%q) = %v, want %v", tt.city, err, ErrInvalidCity)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("validateCity(%q) = %v, want nil", tt.city, err)
+		}
+	}
+}
+
+func TestNormalizePlaceName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Paris", "Paris"},
+		{"  paris. ", "paris"},
+		{"São   Paulo?!", "São Paulo"},
+		{"Zürich,", "Zürich"},
+		{"Montréal\t\n", "Montréal"},
+		{"New York", "New York"},
+		{"St. Louis", "St. Louis"},
+		{"Frankfurt (Oder)", "Frankfurt (Oder)"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizePlaceName(tt.in); got != tt.want {
+			t.Errorf("normalizePlaceName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayCityName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"paris", "Paris"},
+		{"são paulo", "São Paulo"},
+		{"île-de-france", "Île-De-France"},
+		{"ñuñoa", "Ñuñoa"},
+		{"winston-salem", "Winston-Salem"},
+		// Any capital means the user chose the casing
+		{"McAllen", "McAllen"},
+		{"dePaul", "dePaul"},
+		{"ZÜRICH", "ZÜRICH"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := displayCityName(tt.in); got != tt.want {
+			t.Errorf("displayCityName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Spellings of the same city share a cache key but keep their display name
+func TestParseQualifiedCityNormalizes(t *testing.T) {
+	tests := []struct {
+		in          string
+		wantName    string
+		wantCountry string
+	}{
+		{"são paulo, br", "São Paulo", "BR"},
+		{"  São  Paulo , BR. ", "São Paulo", "BR"},
+		{"SÃO PAULO, br", "SÃO PAULO", "BR"},
+	}
+	for _, tt := range tests {
+		loc := parseQualifiedCity(tt.in)
+		if loc.Name != tt.wantName || loc.Country != tt.wantCountry {
+			t.Errorf("parseQualifiedCity(%q) = %s, %s, want %s, %s", tt.in, loc.Name, loc.Country, tt.wantName, tt.wantCountry)
+		}
+		if key := loc.key(); key != "são paulo,br" {
+			t.Errorf("parseQualifiedCity(%q).key() = %q, want %q", tt.in, key, "são paulo,br")
+		}
+	}
+}
+
+// A question about something other than weather is told apart from a reply that could
+// not be made sense of, both in the error and in what the user is told
+func TestExtractLocationNoCityVersusFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      string
+		replyErr   error
+		question   string
+		wantNoCity bool // ErrNoCity rather than a failure
+		wantUser   string
+		wantCause  error
+	}{
+		{"not about weather", "NO_CITY", nil, "what's 2+2?", true, "I can only help with weather", nil},
+		{"no city in a sentence", "Sorry, NO_CITY.", nil, "tell me a joke", true, "I can only help with weather", nil},
+		{"unusable reply", "I am not sure which place you are asking about", nil, "weather somewhere nice", false, "Error: ", nil},
+		{"junk city", `"https://evil.example"`, nil, "weather at https://evil.example", false, "I couldn't tell which city you meant", ErrInvalidCity},
+		{"city not in the question", `"Tokyo"`, nil, "how about the weather where I am?", false, "I couldn't tell which city you meant", ErrInvalidCity},
+		{"LLM failure", "", errBoom, "is it sunny in Oslo?", false, "Error: ", errBoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeLLM{complete: func(system, user string) (string, error) { return tt.reply, tt.replyErr }}
+			_, _, _, err := extractLocation(context.Background(), llm, defaultExtractPrompt, nil, tt.question)
+			if err == nil {
+				t.Fatalf("extractLocation(%q) succeeded, want an error", tt.question)
+			}
+			if errors.Is(err, ErrNoCity) != tt.wantNoCity {
+				t.Errorf("extractLocation(%q) = %v, want ErrNoCity: %v", tt.question, err, tt.wantNoCity)
+			}
+			if tt.wantCause != nil && !errors.Is(err, tt.wantCause) {
+				t.Errorf("extractLocation(%q) = %v, want %v", tt.question, err, tt.wantCause)
+			}
+			if msg := userErrorMessage(err); !strings.HasPrefix(msg, tt.wantUser) {
+				t.Errorf("user is told %q, want %q...", msg, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Configure the default slog logger from LOG_LEVEL (debug, info, warn, error)
+// and LOG_FORMAT (text or json). Logs always go to stderr, tagged with the
+// request ID when logged with a request's context
+func setupLogger() error {
+	var level slog.Level
+	switch strings.ToLower(envOrDefault("LOG_LEVEL", "info")) {
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return fmt.Errorf("invalid LOG_LEVEL %q: must be one of debug, info, warn, error", os.Getenv("LOG_LEVEL"))
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(envOrDefault("LOG_FORMAT", "text")) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q: must be text or json", os.Getenv("LOG_FORMAT"))
+	}
+
+	slog.SetDefault(slog.New(requestIDHandler{handler}))
+	return nil
+}
@@ -1,287 +1,694 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"time"
 
 	"regexp"
 
-	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 
-	"github.com/gage-technologies/mistral-go"
 	"github.com/joho/godotenv"
-	"golang.org/x/net/context"
 )
 
-// Load the API key from the .env file
-func getAPIKey(envVar string) (string, error) {
+// Shared HTTP client for all outbound requests so connections are reused
+var httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+// Version of the build, set with -ldflags "-X main.version=..."
+var version = "dev"
+
+// User-Agent sent on outbound requests so API providers can identify the tool
+var userAgent = defaultUserAgent()
+
+func defaultUserAgent() string {
+	return "weather-assistant/" + version
+}
+
+// Load variables from a .env file into the environment, if one exists.
+// Variables already set in the environment take precedence
+func loadEnvFile() error {
 	err := godotenv.Load()
-	if err != nil {
-		return "", fmt.Errorf("error loading .env file: %v", err)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error loading .env file: %v", err)
 	}
+	return nil
+}
+
+// Load the API key from the environment. A file named by envVar_FILE, as used for
+// Docker and Kubernetes secrets, takes precedence over the variable itself
+func getAPIKey(envVar string) (string, error) {
+	if path := os.Getenv(envVar + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", envVar, err)
+		}
+		apiKey := strings.TrimSpace(string(b))
+		if apiKey == "" {
+			return "", fmt.Errorf("%w: %s_FILE %s is empty", ErrMissingAPIKey, envVar, path)
+		}
+		return apiKey, nil
+	}
+
 	apiKey := os.Getenv(envVar)
 	if apiKey == "" {
-		return "", fmt.Errorf("%s not set in .env file", envVar)
+		return "", fmt.Errorf("%w: %s not set in the environment or .env file", ErrMissingAPIKey, envVar)
 	}
 	return apiKey, nil
 }
 
-// Extract the city name using Mistral
-func extractCityFromUserInput(userMessage string) (string, error) {
-	apiKey, err := getAPIKey("MISTRAL_API_KEY")
+// Extract the city name using the LLM, instructed by the system prompt
+// Earlier exchanges are included so a follow-up without a city can reuse the previous one
+func extractCityFromUserInput(ctx context.Context, llm LLMClient, prompt string, history []Message, userMessage string) (string, Usage, error) {
+	defer observeStage("extract", time.Now())
+
+	//create a context with timeout
+	ctx, cancel := stageContext(ctx, extractBudgetShare)
+	defer cancel()
+
+	// Ask the LLM to identify the city in the user's input
+	responseText, usage, err := completeWithRetry(ctx, llm, extractionParams, prompt, history, userMessage)
+	countLLMCall(err)
 	if err != nil {
-		return "", err
+		return "", usage, err
 	}
+	slog.DebugContext(ctx, "city extraction token usage", "prompt", usage.PromptTokens, "completion", usage.CompletionTokens, "total", usage.TotalTokens)
 
-	client := mistral.NewMistralClientDefault(apiKey)
-	model := mistral.ModelOpenMistral7b
+	city, err := parseCityFromResponse(responseText)
+	if err != nil {
+		return "", usage, err
+	}
 
-	//create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	// Don't trust the model blindly, a crafted message may have talked it into something else
+	if err := checkExtractedCity(city, history, userMessage); err != nil {
+		return "", usage, err
+	}
+	return city, usage, nil
+}
+
+// Words that have no business in a city name but show up when a model was hijacked
+var suspiciousCityWords = []string{"ignore", "instruction", "prompt", "system", "assistant", "http"}
+
+// Check that the city the model returned looks like a place name and actually appears
+// in the conversation, rather than being text a crafted message made it produce
+func checkExtractedCity(city string, history []Message, userMessage string) error {
+	if strings.ContainsAny(city, "<>{}[]`|\\\n") {
+		return fmt.Errorf("%w: it contains unexpected characters", ErrInvalidCity)
+	}
+	lower := strings.ToLower(city)
+	for _, word := range suspiciousCityWords {
+		if strings.Contains(lower, word) {
+			return fmt.Errorf("%w: it contains %q", ErrInvalidCity, word)
+		}
+	}
 
-	//Simulate networ latency or clocking operation within the context
-	done := make(chan struct{})
-	var resp *mistral.ChatCompletionResponse
-
-	go func() {
-		// Ask Mistral to identify the city in the user's input
-		messages := []mistral.ChatMessage{
-			{
-				Role:    mistral.RoleSystem,
-				Content: "You are a weather assistant. Please extract only the city name in the following sentence and make sure the city is within quotes.",
-			},
-			{
-				Role:    mistral.RoleUser,
-				Content: userMessage,
-			},
+	// Only the city itself has to appear, the state and country are ISO codes the model adds
+	name := strings.Split(city, ",")[0]
+	if mentionsPlace(userMessage, name) {
+		return nil
+	}
+	for _, msg := range history {
+		if mentionsPlace(msg.Content, name) {
+			return nil
 		}
+	}
+	return fmt.Errorf("%w: %q does not appear in the question", ErrInvalidCity, normalizePlaceName(name))
+}
 
-		params := mistral.DefaultChatRequestParams
-		// params.MaxTokens = 50
-		// params.Temperature = 0
+// Shortest name a misspelling in the question is matched against
+const minFuzzyNameLength = 5
 
-		resp, err = client.Chat(model, messages, &params)
-		close(done)
-	}()
+// Report whether text mentions the place, ignoring case and accents. A misspelling
+// close enough to a longer name counts, e.g. "Londn", as do the initials of a name of
+// several words, e.g. "LA" for Los Angeles or "NYC" for New York (City)
+func mentionsPlace(text, name string) bool {
+	name = foldPlaceName(name)
+	if name == "" {
+		return false
+	}
+	folded := foldPlaceName(text)
+	if strings.Contains(folded, name) {
+		return true
+	}
 
-	select {
-	case <-ctx.Done():
-		//handle context cancellation, e.g., timeout
-		return "", fmt.Errorf("request timed out")
-	case <-done:
-		//proceed with processing the response
-		if err != nil {
-			return "", err
+	nameWords := strings.FieldsFunc(name, isWordSeparator)
+	if len(nameWords) == 0 {
+		return false
+	}
+	words := strings.FieldsFunc(folded, isWordSeparator)
+	if len(nameWords) > 1 {
+		var initials strings.Builder
+		for _, w := range nameWords {
+			initials.WriteString(string([]rune(w)[:1]))
 		}
-
-		if len(resp.Choices) == 0 {
-			return "", fmt.Errorf("no response choices from Mistral API")
+		for _, w := range words {
+			if w == initials.String() || w == initials.String()+"c" {
+				return true
+			}
+		}
+	}
+	// Short names are too near too many words, e.g. "home" and Rome
+	if len([]rune(name)) < minFuzzyNameLength {
+		return false
+	}
+	for i := 0; i+len(nameWords) <= len(words); i++ {
+		if nameSimilarity(strings.Join(words[i:i+len(nameWords)], " "), strings.Join(nameWords, " ")) >= minNameSimilarity {
+			return true
 		}
-		// Extract and return the city name
-		responseText := strings.TrimSpace(resp.Choices[0].Message.Content)
-		re := regexp.MustCompile(`(?i)"([^"]+)"`) //matches text within quotes
-		matches := re.FindStringSubmatch(responseText)
-		if len(matches) < 2 {
-			return "", fmt.Errorf("could not extract city name from Mistral's response")
+	}
+	return false
+}
+
+// Phrases models like to put in front of the city when they don't quote it
+var cityLeadPhrases = []string{
+	"the city mentioned is",
+	"the city name is",
+	"the city is",
+	"city name:",
+	"city:",
+}
+
+// Pick the city name out of the LLM response. The city is expected within quotes,
+// but if the model didn't comply the first sentence of the reply is used instead
+func parseCityFromResponse(responseText string) (string, error) {
+	responseText = strings.TrimSpace(responseText)
+
+	// The model's way of saying the question is not about any place
+	if strings.Contains(responseText, noCityMarker) {
+		return "", ErrNoCity
+	}
+
+	re := regexp.MustCompile(`(?i)"([^"]+)"`) //matches text within quotes
+	if matches := re.FindStringSubmatch(responseText); len(matches) >= 2 {
+		if city := strings.TrimSpace(matches[1]); city != "" {
+			return city, nil
 		}
+	}
 
-		city := matches[1]
-		city = strings.TrimSpace(city)
+	// Fall back to the first sentence (or line) of the unquoted response
+	candidate := responseText
+	if i := strings.IndexByte(candidate, '\n'); i >= 0 {
+		candidate = candidate[:i]
+	}
+	if i := strings.Index(candidate, ". "); i >= 0 {
+		candidate = candidate[:i]
+	}
+	candidate = strings.TrimSpace(candidate)
 
-		return city, nil
+	lower := strings.ToLower(candidate)
+	for _, phrase := range cityLeadPhrases {
+		if strings.HasPrefix(lower, phrase) {
+			candidate = strings.TrimSpace(candidate[len(phrase):])
+			break
+		}
+	}
+	candidate = strings.Trim(candidate, " .,;:!?'`*")
+
+	// Anything long is a sentence rather than a place name
+	if candidate == "" || len(strings.Fields(candidate)) > 5 {
+		return "", fmt.Errorf("could not extract city name from the LLM response")
 	}
+	return candidate, nil
 }
 
 // Fetch the weather data from OpenWeather API
-func fetchWeatherData(city string) (map[string]interface{}, error) {
+func fetchWeatherData(ctx context.Context, loc Location, units Units, lang string) (*WeatherData, error) {
+	defer observeStage("fetch", time.Now())
+
+	// Serve repeated questions about the same location from the cache
+	key := cacheKey(loc, units, lang)
+	if currentCache != nil {
+		if data, ok := currentCache.Get(key); ok {
+			slog.DebugContext(ctx, "using cached weather data", "location", loc.String())
+			return data, nil
+		}
+	}
+
 	apiKey, err := getAPIKey("WEATHER_API_KEY")
 	if err != nil {
 		return nil, err
 	}
 
-	// URL-encode the city name to ensure it is safe for inclusion in a URL
-	encodedCity := url.QueryEscape(strings.TrimSpace(city))
+	url := fmt.Sprintf("%s/data/2.5/weather?%s&appid=%s&units=%s&lang=%s", openWeatherBaseURL, loc.query(), apiKey, units, lang)
 
-	url := fmt.Sprintf("https://api.openweathermap.org/data/2.5/weather?q=%s&appid=%s&units=metric", encodedCity, apiKey)
-
-	//log the API URL for debbuging
-	log.Printf("Requesting weather data with URL: %s", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
+	var weatherData WeatherData
+	if err := getJSON(ctx, url, &weatherData); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Check if the response status code is not 200 (OK)
-	if resp.StatusCode != http.StatusOK {
-		// Read the body in case of an error to get more details
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch weather data: status code %d, response: %s", resp.StatusCode, string(body))
-	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	// Make sure the fields we rely on are actually present
+	if err := weatherData.validate(); err != nil {
 		return nil, err
 	}
 
-	var weatherData map[string]interface{}
-	err = json.Unmarshal(body, &weatherData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %v, response body: %s", err, string(body))
+	if currentCache != nil {
+		currentCache.Set(key, &weatherData)
 	}
 
-	return weatherData, nil
+	return &weatherData, nil
+}
+
+// parseError is returned when an upstream response body is not the JSON we expect
+type parseError struct {
+	Err  error
+	Body string
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("failed to parse JSON: %v, response body: %s", e.Err, e.Body)
 }
 
-// Generate a response using Mistral with the weather data
-func generateWeatherResponse(userMessage string, weatherData map[string]interface{}) (string, error) {
-	apiKey, err := getAPIKey("MISTRAL_API_KEY")
+// Perform a GET request against a weather API and decode the JSON body into target,
+// retrying transient failures
+func getJSON(ctx context.Context, requestURL string, target interface{}) error {
+	// The URL carries the API key, so only ever log the redacted form, and only at debug
+	// level since it is noise in normal use
+	redacted := redactURL(requestURL)
+	slog.DebugContext(ctx, "requesting weather data", "url", redacted)
+
+	weatherAPICallsTotal.Inc()
+	start := time.Now()
+	defer func() {
+		slog.DebugContext(ctx, "weather request finished", "url", redacted, "duration", time.Since(start))
+	}()
+
+	err := withRetry(ctx, maxRetries, func() error {
+		// Wait for the rate limiter rather than risk a 429 and key suspension
+		if err := rateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+		if err := fetchSlots.Acquire(ctx); err != nil {
+			return err
+		}
+		defer fetchSlots.Release()
+
+		// Tie the request to ctx so a deadline or Ctrl-C aborts it mid-flight
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return redactURLError(err, redacted)
+		}
+		req.Header.Set("User-Agent", userAgent)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return redactURLError(err, redacted)
+		}
+		defer resp.Body.Close()
+
+		// Check if the response status code is not 200 (OK)
+		if resp.StatusCode != http.StatusOK {
+			// Read the body in case of an error to get more details
+			body, _ := ioutil.ReadAll(resp.Body)
+			return &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		// OpenWeather sometimes reports errors in the body of a 200 response
+		if err := checkCod(body); err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(body, target)
+		if err != nil {
+			return &parseError{Err: err, Body: string(body)}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return "", err
+		weatherAPIErrorsTotal.Inc()
 	}
+	return err
+}
 
-	client := mistral.NewMistralClientDefault(apiKey)
-	model := mistral.ModelOpenMistral7b
+// openWeatherStatus is the status OpenWeather repeats in its response bodies. cod is a
+// number in some endpoints and a string in others
+type openWeatherStatus struct {
+	Cod     json.RawMessage `json:"cod"`
+	Message string          `json:"message"`
+}
 
-	// Format the weather data into a string
-	weatherInfo, err := formatWeatherResponse(weatherData)
+// Turn a non-200 cod in a JSON object body into a statusError carrying its message
+func checkCod(body []byte) error {
+	var status openWeatherStatus
+	if json.Unmarshal(body, &status) != nil || len(status.Cod) == 0 {
+		// Not an object with a cod; decoding into the target reports any problem
+		return nil
+	}
+	cod, err := strconv.Atoi(strings.Trim(string(status.Cod), `"`))
+	if err != nil || cod == http.StatusOK {
+		return nil
+	}
+	message := status.Message
+	if message == "" {
+		message = string(body)
+	}
+	return &statusError{StatusCode: cod, Body: message}
+}
+
+// Replace the appid query parameter with a placeholder so URLs can be logged safely
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return "", err
+		return "<unparseable URL>"
+	}
+	q := u.Query()
+	if q.Has("appid") {
+		q.Set("appid", "REDACTED")
+		u.RawQuery = q.Encode()
 	}
+	return u.String()
+}
+
+// URL errors embed the request URL, so swap in the redacted form before they can be logged
+func redactURLError(err error, redacted string) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = redacted
+	}
+	return err
+}
+
+// Generate a response using the LLM with the formatted weather information, prompted and
+// sampled as configured. Earlier exchanges are included so follow-up questions make sense.
+// When onToken is not nil and the LLM supports it, the response is streamed to onToken as
+// it is generated
+func generateWeatherResponse(ctx context.Context, llm LLMClient, cfg *Config, history []Message, userMessage string, weatherInfo string, onToken func(string)) (string, Usage, error) {
+	defer observeStage("generate", time.Now())
 
 	//create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := stageContext(ctx, generateBudgetShare)
 	defer cancel()
 
-	//Simulate networ latency or clocking operation within the context
-	done := make(chan struct{})
-	var resp *mistral.ChatCompletionResponse
-
-	go func() {
-		// Pass the formatted weather information and user message to Mistral
-		messages := []mistral.ChatMessage{
-			{
-				Role:    mistral.RoleSystem,
-				Content: "You are a weather assistant. Use the following weather information to answer the user's question.",
-			},
-			{
-				Role:    mistral.RoleSystem,
-				Content: weatherInfo,
-			},
-			{
-				Role:    mistral.RoleUser,
-				Content: userMessage,
-			},
-		}
+	// Pass the formatted weather information and user message to the LLM
+	system, err := renderResponsePrompt(cfg.ResponsePrompt, weatherInfo, cfg.Lang)
+	if err != nil {
+		return "", Usage{}, err
+	}
 
-		params := mistral.DefaultChatRequestParams
-		// params.MaxTokens = 50
-		// params.Temperature = 0
+	var response string
+	var usage Usage
+	if streamer, ok := llm.(StreamingLLM); ok && onToken != nil {
+		response, usage, err = streamer.Stream(ctx, cfg.responseParams(), system, history, userMessage, onToken)
+	} else {
+		response, usage, err = completeWithRetry(ctx, llm, cfg.responseParams(), system, history, userMessage)
+	}
+	countLLMCall(err)
+	if err == nil {
+		slog.DebugContext(ctx, "response generation token usage", "prompt", usage.PromptTokens, "completion", usage.CompletionTokens, "total", usage.TotalTokens)
+	}
+	return response, usage, err
+}
 
-		resp, err = client.Chat(model, messages, &params)
-		close(done)
-	}()
+// Format the weather data into a human-readable format
+func formatWeatherResponse(data *WeatherData, f Formatter) (string, error) {
+	if err := data.validate(); err != nil {
+		return "", err
+	}
 
-	select {
-	case <-ctx.Done():
-		//handle context cancellation, e.g., timeout
-		return "", fmt.Errorf("request timed out")
-	case <-done:
-		//proceed with processing the response
-		if err != nil {
-			return "", err
+	// Build the sentence from the selected readings that are reported. Conditions and
+	// temperature lead it in when they come first
+	type phrase struct{ name, text string }
+	var phrases []phrase
+	advice := false
+	for _, field := range f.fields() {
+		advice = advice || field.name == "advice"
+		if text := field.phrase(data, f); text != "" {
+			phrases = append(phrases, phrase{field.name, text})
 		}
+	}
 
-		if len(resp.Choices) == 0 {
-			return "", fmt.Errorf("no response choices from Mistral API")
+	summary := "The current weather"
+	if data.Name != "" {
+		summary += " in " + data.Name
+	}
+	sep := ":"
+	if len(phrases) > 0 && phrases[0].name == "conditions" {
+		summary += " is " + phrases[0].text
+		phrases, sep = phrases[1:], ","
+		if len(phrases) > 0 && phrases[0].name == "temp" {
+			summary += " with " + phrases[0].text
+			phrases = phrases[1:]
 		}
+	} else if len(phrases) > 0 && phrases[0].name == "temp" {
+		summary += " has " + phrases[0].text
+		phrases, sep = phrases[1:], ","
+	}
+	for _, p := range phrases {
+		summary += sep + " " + p.text
+		sep = ","
+	}
+	summary += "."
+
+	if hint := clothingHint(data, f.Units); advice && hint != "" {
+		summary += " " + hint
+	}
 
-		// Return the final response from Mistral
-		responseMessage := strings.TrimSpace(resp.Choices[0].Message.Content)
-		return responseMessage, nil
+	// Official warnings matter more than anything else, so they go first
+	if alerts := formatAlerts(data.Alerts, data.Timezone); alerts != "" {
+		summary = alerts + "\n\n" + summary
 	}
+	return summary, nil
 }
 
-// Format the weather data into a human-readable format
-func formatWeatherResponse(data map[string]interface{}) (string, error) {
-	// check if main exists and its a map
-	mainData, ok := data["main"].(map[string]interface{})
-	if !ok || mainData == nil {
-		return "", fmt.Errorf("unexpected response format: 'main' key missing or invalid")
+// Format the weather data as one labeled line per selected reading for the LLM prompt.
+// Readings that are not reported are left out
+func formatWeatherFields(data *WeatherData, f Formatter) (string, error) {
+	if err := data.validate(); err != nil {
+		return "", err
 	}
 
-	// check if the "weather" key exists and is a slice of interfaces
-	weatherData, ok := data["weather"].([]interface{})
-	if !ok || len(weatherData) == 0 {
-		return "", fmt.Errorf("unexpected response format: 'weather' key missing or invalid")
+	var lines []string
+	if data.Name != "" {
+		lines = append(lines, "Location: "+data.Name)
+	}
+	// The city's clock rather than the server's, so "tonight" or "this morning" are judged
+	// by the place asked about
+	lines = append(lines, "Local time: "+formatLocalTime(time.Now().Unix(), data.Timezone))
+	for _, field := range f.fields() {
+		lines = append(lines, field.lines(data, f)...)
 	}
 
-	// Get the first item from the "weather" slice and ensure it's a map
-	weatherItem, ok := weatherData[0].(map[string]interface{})
-	if !ok || weatherItem == nil {
-		return "", fmt.Errorf("unexpected response format: 'weather[0]' item missing or invalid")
+	fields := strings.Join(lines, "\n")
+	// Official warnings matter more than anything else, so they go first
+	if alerts := formatAlerts(data.Alerts, data.Timezone); alerts != "" {
+		fields = alerts + "\n\n" + fields
 	}
+	return fields, nil
+}
 
-	// Extract the fields safely
-	temperature, tempOk := mainData["temp"].(float64)
-	description, descOk := weatherItem["description"].(string)
-	city, cityOk := data["name"].(string)
+// Print the models available to the API key and return the exit code
+func listModels(llm *MistralLLM) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	// Ensure fields were extracted successfully
-	if !tempOk || !descOk || !cityOk {
-		return "", fmt.Errorf("unexpected response format: missing or invalid field(s)")
+	ids, err := llm.ListModels(ctx)
+	if err != nil {
+		fmt.Println("Error listing models:", err)
+		return exitUpstream
 	}
-
-	return fmt.Sprintf("The current weather in %s is %s with a temperature of %.2f℃.", city, description, temperature), nil
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+	return exitOK
 }
 
 // Main function
 func main() {
-	fmt.Println("Ask about the weather")
-	scanner := bufio.NewScanner(os.Stdin)
-	if scanner.Scan() {
-		userMessage := scanner.Text()
+	// The .env file is read once, before any configuration is looked up
+	if err := loadEnvFile(); err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(exitConfig)
+	}
 
-		// Step 1: Extract the city from the user's message
-		city, err := extractCityFromUserInput(userMessage)
-		if err != nil {
-			fmt.Println("Error extracting city:", err)
-			return
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(exitConfig)
+	}
+	if err := setupLogger(); err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(exitConfig)
+	}
+	for _, w := range cfg.Warnings {
+		slog.Warn(w.Msg, w.Args...)
+	}
+	if cfg.ClearCache {
+		os.Exit(clearCache(os.Stdout, cfg.CacheDir, cfg.City))
+	}
+	if cfg.Check {
+		if !runCheck(os.Stdout, cfg) {
+			os.Exit(exitConfig)
 		}
-		//ensure city is not empty
-		if city == "" {
-			fmt.Println("Could not extract city from your input")
-			return
+		return
+	}
+
+	httpClient.Timeout = cfg.HTTPTimeout
+	if cfg.NoNetwork {
+		disableNetwork()
+	}
+	userAgent = cfg.UserAgent
+	geoIPURL = cfg.GeoIPURL
+	maxRetries = cfg.MaxRetries
+	retryBaseDelay, retryMaxDelay = cfg.RetryBase, cfg.RetryMax
+	rateLimiter = newRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	fetchSlots = newSemaphore(cfg.Concurrency)
+	openWeatherBaseURL = cfg.BaseURL
+	if cfg.NoCache {
+		currentCache = nil
+		geocodeCache = nil
+		pressureHistory = nil
+	} else {
+		currentCache = newTTLCache[*WeatherData](cfg.CacheTTL, cfg.CacheSize)
+		geocodeCache = newTTLCache[[]Location](defaultGeocodeCacheTTL, cfg.CacheSize)
+		pressureHistory = newTTLCache[pressureSample](pressureSampleTTL, cfg.CacheSize)
+
+		// Back the caches with files so results survive between runs
+		if cfg.PersistCache {
+			if err := os.MkdirAll(cfg.CacheDir, 0o700); err != nil {
+				fmt.Println("Error in configuration:", err)
+				os.Exit(exitConfig)
+			}
+			currentCache.disk = newDiskStore[*WeatherData](filepath.Join(cfg.CacheDir, "weather.json"), cfg.CacheTTL)
+			geocodeCache.disk = newDiskStore[[]Location](filepath.Join(cfg.CacheDir, "geocode.json"), defaultGeocodeCacheTTL)
+			pressureHistory.disk = newDiskStore[pressureSample](filepath.Join(cfg.CacheDir, "pressure.json"), pressureSampleTTL)
 		}
-		//log the extracted city name
-		log.Printf("Extracted city: %s", city)
+	}
+
+	if cfg.Days > forecastDays {
+		slog.Warn("-days is beyond the forecast horizon, summarizing all forecast days instead", "days", cfg.Days, "max", forecastDays)
+		cfg.Days = forecastDays
+	}
+
+	offlineMode = cfg.Offline
+	if cfg.Offline {
+		slog.Warn("offline mode is active: answers use made-up weather data, not real conditions")
+	}
+	if cfg.NoNetwork {
+		slog.Info("network access is disabled: any outbound request will fail")
+	}
 
-		// Step 2: Fetch the weather data for the extracted city
-		weatherData, err := fetchWeatherData(city)
+	// Without a Mistral key the assistant still works, the same way as with -no-llm
+	// An unreadable MISTRAL_API_KEY_FILE is a configuration error reported below instead
+	if _, err := getAPIKey("MISTRAL_API_KEY"); errors.Is(err, ErrMissingAPIKey) && !cfg.NoLLM && !cfg.ListModels {
+		slog.Warn("MISTRAL_API_KEY is not set, LLM features are disabled: cities are found with a simple heuristic and answers are plain weather summaries")
+		cfg.NoLLM = true
+	}
+
+	// The Mistral key is only needed when the LLM is used
+	var llm LLMClient
+	switch {
+	case cfg.NoLLM:
+	case cfg.NoNetwork:
+		// Questions fail with ErrNetworkDisabled instead of reaching Mistral
+		llm = noNetworkLLM{}
+	default:
+		apiKey, err := getAPIKey("MISTRAL_API_KEY")
 		if err != nil {
-			log.Fatalf("Error fetching weather data: %v", err)
-			return
+			fmt.Println("Error in configuration:", err)
+			os.Exit(exitConfig)
+		}
+		mistralLLM := NewMistralLLM(apiKey, cfg.Model)
+		if cfg.ListModels {
+			os.Exit(listModels(mistralLLM))
 		}
+		slog.Info("using Mistral model", "model", cfg.Model)
+		mistralLLM.checkModel(context.Background())
+		llm = mistralLLM
+	}
 
-		// Step 3: Generate the final response using Mistral
-		response, err := generateWeatherResponse(userMessage, weatherData)
+	provider, err := newWeatherProvider(cfg)
+	if err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(exitConfig)
+	}
+
+	// A single assistant, and with it a single Mistral client, serves every question
+	assistant := NewAssistant(cfg, llm, provider)
+
+	// Ctrl-C cancels this context, aborting any in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Serve {
+		if err := serve(ctx, cfg, assistant); err != nil {
+			fmt.Println("Error running server:", err)
+			os.Exit(exitFailure)
+		}
+		return
+	}
+
+	out, err := openOutput(cfg.Output)
+	if err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(exitConfig)
+	}
+	// Files are written unbuffered, so answers already written survive an early os.Exit
+	defer closeOutput(out)
+
+	if cfg.Batch != "" {
+		code := runBatch(ctx, cfg, assistant, out)
+		closeOutput(out)
+		os.Exit(code)
+	}
+
+	// With -once and no arguments, the question is the first line of stdin
+	if cfg.Once && !cfg.QuestionGiven {
+		cfg.Question, err = readFirstLine(os.Stdin)
 		if err != nil {
-			fmt.Println("Error generating response:", err)
-			return
+			fmt.Fprintln(os.Stderr, "Error reading question:", err)
+			os.Exit(exitFailure)
+		}
+	}
+
+	// A question given as arguments is answered once, without the interactive prompt
+	if cfg.Once || cfg.QuestionGiven {
+		if cfg.Question == "" {
+			fmt.Fprintln(os.Stderr, "Please type a question about the weather.")
+			os.Exit(exitFailure)
 		}
+		runOnce(ctx, cfg, assistant, cfg.Question, out)
+		return
+	}
 
-		// Output the final response to the user
-		fmt.Println(response)
+	runREPL(ctx, cfg, assistant, os.Stdin, out)
+}
 
+// Open the -output destination: stdout, or the file at path with answers appended to it
+func openOutput(path string) (*os.File, error) {
+	if path == "" {
+		return os.Stdout, nil
 	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open -output: %w", err)
+	}
+	return f, nil
+}
 
+// Close an -output file, exiting with an error if the answers may not have been saved
+func closeOutput(f *os.File) {
+	if f == os.Stdout {
+		return
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error closing output:", err)
+		os.Exit(exitFailure)
+	}
 }
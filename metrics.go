@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exposed on /metrics in -serve mode
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_assistant_requests_total",
+		Help: "Total number of weather questions handled, by HTTP status code.",
+	}, []string{"code"})
+
+	llmCallsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_assistant_llm_calls_total",
+		Help: "Total number of LLM calls made.",
+	})
+	llmErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_assistant_llm_errors_total",
+		Help: "Total number of LLM calls that failed.",
+	})
+
+	extractionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "weather_assistant_extractions_total",
+		Help: "Total number of locations extracted from questions, by extraction method.",
+	}, []string{"method"})
+
+	weatherAPICallsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_assistant_weather_api_calls_total",
+		Help: "Total number of weather API calls made.",
+	})
+	weatherAPIErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "weather_assistant_weather_api_errors_total",
+		Help: "Total number of weather API calls that failed.",
+	})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weather_assistant_request_duration_seconds",
+		Help:    "End-to-end latency of answering a weather question.",
+		Buckets: prometheus.DefBuckets,
+	}, nil)
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "weather_assistant_stage_duration_seconds",
+		Help:    "Latency of each pipeline stage: extract, fetch or generate.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"stage"})
+)
+
+// Record how long a pipeline stage took, for use with defer
+func observeStage(stage string, start time.Time) {
+	stageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+// Count an LLM call and whether it failed
+func countLLMCall(err error) {
+	llmCallsTotal.Inc()
+	if err != nil {
+		llmErrorsTotal.Inc()
+	}
+}
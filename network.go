@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrNetworkDisabled is returned for any outbound call attempted with -no-network
+var ErrNetworkDisabled = errors.New("network access is disabled by -no-network")
+
+// blockingTransport fails every request to a host other than the local machine, so
+// mock servers on localhost keep working
+type blockingTransport struct {
+	local http.RoundTripper
+}
+
+func (t blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isLoopbackHost(req.URL.Hostname()) {
+		return t.local.RoundTrip(req)
+	}
+	return nil, fmt.Errorf("%w: refusing request to %s", ErrNetworkDisabled, req.URL.Host)
+}
+
+// Report whether host names the local machine
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Block outbound HTTP for the shared client and every client using the default
+// transport, which includes the Mistral client
+func disableNetwork() {
+	transport := blockingTransport{local: http.DefaultTransport}
+	http.DefaultTransport = transport
+	httpClient.Transport = transport
+}
+
+// noNetworkLLM stands in for Mistral with -no-network, failing every call
+type noNetworkLLM struct{}
+
+func (noNetworkLLM) Complete(ctx context.Context, params SamplingParams, system string, history []Message, user string) (string, Usage, error) {
+	return "", Usage{}, fmt.Errorf("%w: refusing Mistral request", ErrNetworkDisabled)
+}
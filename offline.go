@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// Set from OFFLINE at startup. Weather then comes from canned data instead of the APIs
+var offlineMode bool
+
+// Canned conditions for offline mode, as OpenWeather condition ID and description
+var offlineConditions = []WeatherCondition{
+	{ID: 800, Main: "Clear", Description: "clear sky"},
+	{ID: 801, Main: "Clouds", Description: "few clouds"},
+	{ID: 803, Main: "Clouds", Description: "broken clouds"},
+	{ID: 500, Main: "Rain", Description: "light rain"},
+	{ID: 741, Main: "Fog", Description: "fog"},
+	{ID: 600, Main: "Snow", Description: "light snow"},
+}
+
+// OfflineProvider makes up plausible weather for demos without API keys. The same
+// city always gets the same weather, and different cities get different weather
+type OfflineProvider struct {
+	Units Units
+}
+
+// Current returns canned current conditions for loc
+func (p *OfflineProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	seed := offlineSeed(loc)
+	temp := offlineTemp(seed, 0)
+	feelsLike := convertTemp(temp-float64(seed%4), UnitsMetric, p.Units)
+	humidity := float64(40 + seed%50)
+	deg := float64(seed % 360)
+	current := convertTemp(temp, UnitsMetric, p.Units)
+
+	data := &WeatherData{
+		Name: loc.String(),
+		Main: &MainData{
+			Temp:      &current,
+			FeelsLike: &feelsLike,
+			Humidity:  &humidity,
+		},
+		Weather: []WeatherCondition{offlineCondition(seed, temp)},
+		Wind:    &WindData{Speed: float64(seed%12) + 0.5, Deg: &deg},
+		Clouds:  &CloudsData{All: float64(seed % 101)},
+	}
+	if data.Name == "" {
+		data.Name = "Somewhere"
+	}
+	return data, nil
+}
+
+// Forecast returns a canned 5 day / 3 hour forecast for loc
+func (p *OfflineProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	return offlineForecast(loc, p.Units), nil
+}
+
+// Canned 5 day / 3 hour forecast for loc
+func offlineForecast(loc Location, units Units) *ForecastData {
+	seed := offlineSeed(loc)
+	data := &ForecastData{City: ForecastCity{Name: loc.String()}}
+
+	start := time.Now().UTC().Truncate(3 * time.Hour)
+	for i := 0; i < forecastDays*8; i++ {
+		temp := offlineTemp(seed, i)
+		converted := convertTemp(temp, UnitsMetric, units)
+		data.List = append(data.List, ForecastEntry{
+			Dt:      start.Add(time.Duration(i) * 3 * time.Hour).Unix(),
+			Main:    MainData{Temp: &converted},
+			Weather: []WeatherCondition{offlineCondition(seed+uint32(i/8), temp)},
+		})
+	}
+	return data
+}
+
+// Canned air quality for loc
+func offlineAirQuality(loc Location) *AirQuality {
+	seed := offlineSeed(loc)
+	return &AirQuality{
+		AQI:  int(1 + seed%3),
+		PM25: float64(5 + seed%20),
+		PM10: float64(10 + seed%30),
+		O3:   float64(40 + seed%60),
+	}
+}
+
+// Pick a canned condition, turning snow into rain when it is too warm for it
+func offlineCondition(seed uint32, temp float64) WeatherCondition {
+	condition := offlineConditions[seed%uint32(len(offlineConditions))]
+	if condition.Main == "Snow" && temp > 2 {
+		condition = offlineConditions[3]
+	}
+	return condition
+}
+
+// Hash the location so each city gets its own, stable, made-up weather
+func offlineSeed(loc Location) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(loc.key()))
+	return h.Sum32()
+}
+
+// Made-up temperature in ℃ for the i-th 3-hour interval, warmer in the afternoon
+func offlineTemp(seed uint32, i int) float64 {
+	base := float64(seed%30) - 5
+	afternoon := []float64{-3, -4, -2, 1, 3, 4, 2, 0}
+	return base + afternoon[(time.Now().UTC().Hour()/3+i)%8]
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// oneCallResponse is the part of the OpenWeather One Call 3.0 response we use
+type oneCallResponse struct {
+	TimezoneOffset int `json:"timezone_offset"`
+	Current        struct {
+		Sunrise    int64              `json:"sunrise"`
+		Sunset     int64              `json:"sunset"`
+		Temp       *float64           `json:"temp"`
+		FeelsLike  *float64           `json:"feels_like"`
+		Humidity   *float64           `json:"humidity"`
+		Pressure   *float64           `json:"pressure"`
+		Visibility *float64           `json:"visibility"`
+		Clouds     float64            `json:"clouds"`
+		WindSpeed  float64            `json:"wind_speed"`
+		WindDeg    *float64           `json:"wind_deg"`
+		Weather    []WeatherCondition `json:"weather"`
+		Rain       *PrecipData        `json:"rain"`
+		Snow       *PrecipData        `json:"snow"`
+	} `json:"current"`
+	Daily []struct {
+		Dt   int64 `json:"dt"`
+		Temp struct {
+			Min float64 `json:"min"`
+			Max float64 `json:"max"`
+		} `json:"temp"`
+		Weather []WeatherCondition `json:"weather"`
+	} `json:"daily"`
+	Alerts []Alert `json:"alerts"`
+}
+
+// OneCallProvider serves current conditions, today's range and weather alerts from
+// the OpenWeather One Call 3.0 API in a single request, and the daily forecast. It
+// needs a One Call subscription
+type OneCallProvider struct {
+	Units Units
+	Lang  string
+}
+
+// Current fetches the current weather for loc from the One Call API
+func (p *OneCallProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	defer observeStage("fetch", time.Now())
+
+	loc, resp, err := p.fetch(ctx, loc, "minutely,hourly")
+	if err != nil {
+		return nil, err
+	}
+
+	c := resp.Current
+	data := &WeatherData{
+		Name:       loc.Name,
+		Coord:      &Coord{Lat: loc.Lat, Lon: loc.Lon},
+		Main:       &MainData{Temp: c.Temp, FeelsLike: c.FeelsLike, Humidity: c.Humidity, Pressure: c.Pressure},
+		Weather:    c.Weather,
+		Wind:       &WindData{Speed: c.WindSpeed, Deg: c.WindDeg},
+		Clouds:     &CloudsData{All: c.Clouds},
+		Rain:       c.Rain,
+		Snow:       c.Snow,
+		Sys:        &SysData{Country: loc.Country, Sunrise: c.Sunrise, Sunset: c.Sunset},
+		Visibility: c.Visibility,
+		Timezone:   resp.TimezoneOffset,
+		Alerts:     resp.Alerts,
+	}
+	if data.Name == "" {
+		data.Name = loc.String()
+	}
+	if len(resp.Daily) > 0 {
+		data.Main.TempMin = &resp.Daily[0].Temp.Min
+		data.Main.TempMax = &resp.Daily[0].Temp.Max
+	}
+	if err := data.validate(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Forecast fetches the daily forecast for loc from the One Call API, as one entry per day
+func (p *OneCallProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	loc, resp, err := p.fetch(ctx, loc, "current,minutely,hourly,alerts")
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Daily) == 0 {
+		return nil, fmt.Errorf("unexpected response format: 'daily' missing or empty")
+	}
+
+	data := &ForecastData{City: ForecastCity{
+		Name:     loc.String(),
+		Coord:    &Coord{Lat: loc.Lat, Lon: loc.Lon},
+		Country:  loc.Country,
+		Timezone: resp.TimezoneOffset,
+	}}
+	for i := range resp.Daily {
+		day := &resp.Daily[i]
+		data.List = append(data.List, ForecastEntry{
+			Dt:      day.Dt,
+			Main:    MainData{TempMin: &day.Temp.Min, TempMax: &day.Temp.Max},
+			Weather: day.Weather,
+		})
+	}
+	return data, nil
+}
+
+// Request the One Call API for loc, leaving out the exclude parts of the response.
+// It returns loc resolved to coordinates
+func (p *OneCallProvider) fetch(ctx context.Context, loc Location, exclude string) (Location, *oneCallResponse, error) {
+	// One Call only takes coordinates
+	if !loc.HasCoords {
+		if loc.Zip != "" {
+			return loc, nil, fmt.Errorf("the One Call provider does not support postal codes, use a city name")
+		}
+		matches, err := geocodeCity(ctx, loc)
+		if err != nil {
+			return loc, nil, err
+		}
+		if len(matches) == 0 {
+			return loc, nil, fmt.Errorf("%w: %s", ErrCityNotFound, loc)
+		}
+		loc = matches[0]
+	}
+
+	apiKey, err := getAPIKey("WEATHER_API_KEY")
+	if err != nil {
+		return loc, nil, err
+	}
+
+	url := fmt.Sprintf("%s/data/3.0/onecall?lat=%s&lon=%s&exclude=%s&appid=%s&units=%s&lang=%s", openWeatherBaseURL,
+		strconv.FormatFloat(loc.Lat, 'f', -1, 64), strconv.FormatFloat(loc.Lon, 'f', -1, 64), exclude, apiKey, p.Units, p.Lang)
+
+	var resp oneCallResponse
+	if err := getJSON(ctx, url, &resp); err != nil {
+		return loc, nil, err
+	}
+	return loc, &resp, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// OpenMeteoProvider serves current conditions and the daily forecast from Open-Meteo,
+// which needs no API key
+type OpenMeteoProvider struct {
+	Units Units
+}
+
+// openMeteoGeocodeResponse is the Open-Meteo geocoding search response
+type openMeteoGeocodeResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		CountryCode string  `json:"country_code"`
+		Admin1      string  `json:"admin1"`
+	} `json:"results"`
+}
+
+// openMeteoForecastResponse is the part of the Open-Meteo forecast response we use
+type openMeteoForecastResponse struct {
+	Current struct {
+		Temperature         *float64 `json:"temperature_2m"`
+		ApparentTemperature *float64 `json:"apparent_temperature"`
+		RelativeHumidity    *float64 `json:"relative_humidity_2m"`
+		WindSpeed           float64  `json:"wind_speed_10m"`
+		WindDirection       *float64 `json:"wind_direction_10m"`
+		WeatherCode         int      `json:"weather_code"`
+		CloudCover          float64  `json:"cloud_cover"`
+		Rain                float64  `json:"rain"`
+		Snowfall            float64  `json:"snowfall"`
+		PressureMSL         *float64 `json:"pressure_msl"`
+	} `json:"current"`
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+}
+
+// openMeteoDailyResponse is the part of the Open-Meteo daily forecast response we use
+type openMeteoDailyResponse struct {
+	Daily struct {
+		Time           []string   `json:"time"` // local dates, e.g. 2025-10-16
+		TemperatureMax []*float64 `json:"temperature_2m_max"`
+		TemperatureMin []*float64 `json:"temperature_2m_min"`
+		WeatherCode    []int      `json:"weather_code"`
+	} `json:"daily"`
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+}
+
+// wmoCondition maps a WMO weather interpretation code onto an OpenWeather-style group and description
+type wmoCondition struct {
+	Main        string
+	Description string
+}
+
+var wmoConditions = map[int]wmoCondition{
+	0:  {"Clear", "clear sky"},
+	1:  {"Clouds", "mainly clear"},
+	2:  {"Clouds", "partly cloudy"},
+	3:  {"Clouds", "overcast"},
+	45: {"Fog", "fog"},
+	48: {"Fog", "depositing rime fog"},
+	51: {"Drizzle", "light drizzle"},
+	53: {"Drizzle", "moderate drizzle"},
+	55: {"Drizzle", "dense drizzle"},
+	56: {"Drizzle", "light freezing drizzle"},
+	57: {"Drizzle", "dense freezing drizzle"},
+	61: {"Rain", "slight rain"},
+	63: {"Rain", "moderate rain"},
+	65: {"Rain", "heavy rain"},
+	66: {"Rain", "light freezing rain"},
+	67: {"Rain", "heavy freezing rain"},
+	71: {"Snow", "slight snow fall"},
+	73: {"Snow", "moderate snow fall"},
+	75: {"Snow", "heavy snow fall"},
+	77: {"Snow", "snow grains"},
+	80: {"Rain", "slight rain showers"},
+	81: {"Rain", "moderate rain showers"},
+	82: {"Rain", "violent rain showers"},
+	85: {"Snow", "slight snow showers"},
+	86: {"Snow", "heavy snow showers"},
+	95: {"Thunderstorm", "thunderstorm"},
+	96: {"Thunderstorm", "thunderstorm with slight hail"},
+	99: {"Thunderstorm", "thunderstorm with heavy hail"},
+}
+
+// Current fetches the current weather for loc from Open-Meteo, geocoding city names first
+func (p *OpenMeteoProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	loc, q, err := p.query(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	q.Set("current", "temperature_2m,apparent_temperature,relative_humidity_2m,wind_speed_10m,wind_direction_10m,weather_code,cloud_cover,rain,snowfall,pressure_msl")
+
+	var resp openMeteoForecastResponse
+	if err := getJSON(ctx, "https://api.open-meteo.com/v1/forecast?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+
+	temp, feelsLike := resp.Current.Temperature, resp.Current.ApparentTemperature
+	p.toKelvin(temp, feelsLike)
+	condition := wmoConditionOf(resp.Current.WeatherCode)
+
+	data := &WeatherData{
+		Name:     loc.String(),
+		Coord:    &Coord{Lat: loc.Lat, Lon: loc.Lon},
+		Main:     &MainData{Temp: temp, FeelsLike: feelsLike, Humidity: resp.Current.RelativeHumidity, Pressure: resp.Current.PressureMSL},
+		Weather:  []WeatherCondition{{Main: condition.Main, Description: condition.Description}},
+		Wind:     &WindData{Speed: resp.Current.WindSpeed, Deg: resp.Current.WindDirection},
+		Clouds:   &CloudsData{All: resp.Current.CloudCover},
+		Timezone: resp.UTCOffsetSeconds,
+	}
+	if resp.Current.Rain > 0 {
+		data.Rain = &PrecipData{OneHour: resp.Current.Rain}
+	}
+	// Snowfall is reported in centimetres
+	if resp.Current.Snowfall > 0 {
+		data.Snow = &PrecipData{OneHour: resp.Current.Snowfall * 10}
+	}
+	if err := data.validate(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Forecast fetches the daily forecast for loc from Open-Meteo, as one entry per day
+func (p *OpenMeteoProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	loc, q, err := p.query(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	q.Set("daily", "temperature_2m_max,temperature_2m_min,weather_code")
+	q.Set("forecast_days", strconv.Itoa(forecastDays))
+
+	var resp openMeteoDailyResponse
+	if err := getJSON(ctx, "https://api.open-meteo.com/v1/forecast?"+q.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	daily := resp.Daily
+	if len(daily.Time) == 0 || len(daily.TemperatureMin) != len(daily.Time) || len(daily.TemperatureMax) != len(daily.Time) || len(daily.WeatherCode) != len(daily.Time) {
+		return nil, fmt.Errorf("unexpected response format: 'daily' missing, empty or uneven")
+	}
+
+	data := &ForecastData{City: ForecastCity{
+		Name:     loc.String(),
+		Coord:    &Coord{Lat: loc.Lat, Lon: loc.Lon},
+		Country:  loc.Country,
+		Timezone: resp.UTCOffsetSeconds,
+	}}
+	zone := data.location()
+	for i, day := range daily.Time {
+		date, err := time.ParseInLocation(time.DateOnly, day, zone)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected response format: %w", err)
+		}
+		p.toKelvin(daily.TemperatureMin[i], daily.TemperatureMax[i])
+		condition := wmoConditionOf(daily.WeatherCode[i])
+		data.List = append(data.List, ForecastEntry{
+			Dt:      date.Unix(),
+			Main:    MainData{TempMin: daily.TemperatureMin[i], TempMax: daily.TemperatureMax[i]},
+			Weather: []WeatherCondition{{Main: condition.Main, Description: condition.Description}},
+		})
+	}
+	return data, nil
+}
+
+// Start the query of a forecast API request for loc, geocoding city names first. It
+// returns loc resolved to coordinates
+func (p *OpenMeteoProvider) query(ctx context.Context, loc Location) (Location, url.Values, error) {
+	if !loc.HasCoords {
+		var err error
+		loc, err = p.geocode(ctx, loc)
+		if err != nil {
+			return loc, nil, err
+		}
+	}
+
+	q := url.Values{}
+	q.Set("latitude", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+	q.Set("longitude", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+	// Without a timezone the response is in UTC and carries no offset for the local time
+	q.Set("timezone", "auto")
+	if p.Units == UnitsImperial {
+		q.Set("temperature_unit", "fahrenheit")
+		q.Set("wind_speed_unit", "mph")
+	} else {
+		q.Set("wind_speed_unit", "ms")
+	}
+	return loc, q, nil
+}
+
+// Open-Meteo has no Kelvin option, so convert the reported temperatures from Celsius
+// in standard units
+func (p *OpenMeteoProvider) toKelvin(temps ...*float64) {
+	if p.Units != UnitsStandard {
+		return
+	}
+	for _, t := range temps {
+		if t != nil {
+			*t += 273.15
+		}
+	}
+}
+
+// Look up a WMO weather code, describing codes missing from the table by number
+func wmoConditionOf(code int) wmoCondition {
+	if condition, ok := wmoConditions[code]; ok {
+		return condition
+	}
+	return wmoCondition{"Unknown", fmt.Sprintf("weather code %d", code)}
+}
+
+// Resolve a city name to coordinates with the Open-Meteo geocoding API
+func (p *OpenMeteoProvider) geocode(ctx context.Context, loc Location) (Location, error) {
+	q := url.Values{}
+	q.Set("name", loc.Name)
+	q.Set("count", "1")
+	// The search also accepts postal codes, narrowed down by country
+	if loc.Zip != "" {
+		q.Set("name", loc.Zip)
+		q.Set("countryCode", loc.Country)
+	}
+
+	var resp openMeteoGeocodeResponse
+	if err := getJSON(ctx, "https://geocoding-api.open-meteo.com/v1/search?"+q.Encode(), &resp); err != nil {
+		return Location{}, err
+	}
+	if len(resp.Results) == 0 {
+		return Location{}, fmt.Errorf("%w: %s", ErrCityNotFound, loc)
+	}
+
+	r := resp.Results[0]
+	return Location{Name: r.Name, State: r.Admin1, Country: r.CountryCode, Lat: r.Latitude, Lon: r.Longitude, HasCoords: true}, nil
+}
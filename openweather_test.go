@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// API key the mock OpenWeather accepts; any other gets a 401
+const testAPIKey = "test-key"
+
+// Responses of the mock OpenWeather, by endpoint path and then by the city asked about
+var openWeatherFixtures = map[string]map[string]string{
+	"/data/2.5/weather": {
+		"Paris":   `{"name":"Paris","coord":{"lat":48.85,"lon":2.35},"main":{"temp":18.4,"feels_like":17.9,"humidity":64,"temp_min":16.2,"temp_max":20.1,"pressure":1015},"weather":[{"id":803,"main":"Clouds","description":"broken clouds"}],"wind":{"speed":4.1,"deg":250},"timezone":7200}`,
+		"London":  `{"name":"London","main":{"temp":20,"feels_like":19,"humidity":70},"weather":[{"id":500,"main":"Rain","description":"light rain"}],"rain":{"1h":0.4},"timezone":3600}`,
+		"Tokyo":   `{"name":"Tokyo","main":{"temp":-2.5,"feels_like":-6,"humidity":40},"weather":[{"id":800,"main":"Clear","description":"clear sky"}],"timezone":32400}`,
+		"Nowhere": `{"cod":"404","message":"city not found"}`,
+		"Garbled": `{"name":"Garbled","main":`,
+	},
+	"/geo/1.0/direct": {
+		"Paris":   `[{"name":"Paris","lat":48.85,"lon":2.35,"country":"FR"},{"name":"Paris","lat":33.66,"lon":-95.56,"country":"US","state":"Texas"}]`,
+		"London":  `[{"name":"London","lat":51.51,"lon":-0.13,"country":"GB","state":"England"}]`,
+		"Tokyo":   `[{"name":"Tokyo","lat":35.68,"lon":139.76,"country":"JP"}]`,
+		"Nowhere": `[]`,
+		"Garbled": `[{"name":`,
+	},
+	"/data/2.5/forecast": {
+		"Paris":   `{"city":{"name":"Paris","country":"FR","timezone":7200},"list":[{"dt":1760608800,"main":{"temp":14.2},"weather":[{"id":500,"main":"Rain","description":"light rain"}]},{"dt":1760619600,"main":{"temp":17.8},"weather":[{"id":500,"main":"Rain","description":"light rain"}]}]}`,
+		"Tokyo":   `{"city":{"name":"Tokyo","country":"JP","timezone":32400},"list":[{"dt":1760608800,"main":{"temp":21},"weather":[{"id":800,"main":"Clear","description":"clear sky"}]}]}`,
+		"Empty":   `{"city":{"name":"Empty"},"list":[]}`,
+		"Garbled": `{"list":[{"dt":`,
+	},
+}
+
+// mockOpenWeather is an httptest.Server standing in for the OpenWeather API
+type mockOpenWeather struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+// Start a mock OpenWeather serving openWeatherFixtures and point the fetchers at it, with
+// the caches, retries and rate limiting out of the way. Everything is restored when the
+// test ends
+func newMockOpenWeather(t *testing.T) *mockOpenWeather {
+	t.Helper()
+	m := &mockOpenWeather{}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+
+	t.Setenv("WEATHER_API_KEY", testAPIKey)
+	t.Setenv("WEATHER_API_KEY_FILE", "")
+
+	oldBaseURL, oldRetries, oldLimiter := openWeatherBaseURL, maxRetries, rateLimiter
+	oldCurrent, oldGeocode := currentCache, geocodeCache
+	t.Cleanup(func() {
+		openWeatherBaseURL, maxRetries, rateLimiter = oldBaseURL, oldRetries, oldLimiter
+		currentCache, geocodeCache = oldCurrent, oldGeocode
+	})
+	openWeatherBaseURL, maxRetries, rateLimiter = m.URL, 0, rate.NewLimiter(rate.Inf, 1)
+	currentCache, geocodeCache = nil, nil
+	return m
+}
+
+func (m *mockOpenWeather) serve(w http.ResponseWriter, r *http.Request) {
+	m.requests.Add(1)
+	q := r.URL.Query()
+	if q.Get("appid") != testAPIKey {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"cod":401,"message":"Invalid API key. Please see https://openweathermap.org/faq#error401 for more info."}`))
+		return
+	}
+	city, _, _ := strings.Cut(q.Get("q"), ",")
+	body, ok := openWeatherFixtures[r.URL.Path][city]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"cod":"404","message":"city not found"}`))
+		return
+	}
+	w.Write([]byte(body))
+}
+
+func TestFetchWeatherData(t *testing.T) {
+	newMockOpenWeather(t)
+
+	tests := []struct {
+		city     string
+		wantTemp float64
+		wantDesc string
+	}{
+		{"Paris", 18.4, "broken clouds"},
+		{"London", 20, "light rain"},
+		{"Tokyo", -2.5, "clear sky"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.city, func(t *testing.T) {
+			data, err := fetchWeatherData(context.Background(), Location{Name: tt.city}, UnitsMetric, "en")
+			if err != nil {
+				t.Fatalf("fetchWeatherData: %v", err)
+			}
+			if data.Name != tt.city || *data.Main.Temp != tt.wantTemp || data.description() != tt.wantDesc {
+				t.Errorf("got %s, %v, %q, want %s, %v, %q", data.Name, *data.Main.Temp, data.description(), tt.city, tt.wantTemp, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestFetchWeatherDataErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		city    string
+		apiKey  string
+		wantErr error
+	}{
+		{"unknown city", "Atlantis", testAPIKey, ErrCityNotFound},
+		{"404 in the body of a 200", "Nowhere", testAPIKey, ErrCityNotFound},
+		{"bad key", "Paris", "wrong-key", ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMockOpenWeather(t)
+			t.Setenv("WEATHER_API_KEY", tt.apiKey)
+			_, err := fetchWeatherData(context.Background(), Location{Name: tt.city}, UnitsMetric, "en")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("fetchWeatherData(%s) = %v, want %v", tt.city, err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("malformed body", func(t *testing.T) {
+		newMockOpenWeather(t)
+		_, err := fetchWeatherData(context.Background(), Location{Name: "Garbled"}, UnitsMetric, "en")
+		var pe *parseError
+		if !errors.As(err, &pe) {
+			t.Errorf("fetchWeatherData(Garbled) = %v, want a parse error", err)
+		}
+	})
+}
+
+func TestGeocodeCity(t *testing.T) {
+	newMockOpenWeather(t)
+
+	tests := []struct {
+		city        string
+		wantMatches int
+		wantFirst   string
+	}{
+		{"Paris", 2, "Paris,FR"},
+		{"London", 1, "London,England,GB"},
+		{"Tokyo", 1, "Tokyo,JP"},
+		{"Nowhere", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.city, func(t *testing.T) {
+			matches, err := geocodeCity(context.Background(), Location{Name: tt.city})
+			if err != nil {
+				t.Fatalf("geocodeCity: %v", err)
+			}
+			if len(matches) != tt.wantMatches {
+				t.Fatalf("got %d matches, want %d", len(matches), tt.wantMatches)
+			}
+			if len(matches) > 0 && (matches[0].qualifiedName() != tt.wantFirst || !matches[0].HasCoords) {
+				t.Errorf("first match = %s (coordinates %v), want %s with coordinates", matches[0].qualifiedName(), matches[0].HasCoords, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestGeocodeCityErrors(t *testing.T) {
+	t.Run("bad key", func(t *testing.T) {
+		newMockOpenWeather(t)
+		t.Setenv("WEATHER_API_KEY", "wrong-key")
+		if _, err := geocodeCity(context.Background(), Location{Name: "Paris"}); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("geocodeCity = %v, want %v", err, ErrInvalidAPIKey)
+		}
+	})
+	t.Run("malformed body", func(t *testing.T) {
+		newMockOpenWeather(t)
+		var pe *parseError
+		if _, err := geocodeCity(context.Background(), Location{Name: "Garbled"}); !errors.As(err, &pe) {
+			t.Errorf("geocodeCity = %v, want a parse error", err)
+		}
+	})
+}
+
+func TestFetchForecastData(t *testing.T) {
+	newMockOpenWeather(t)
+
+	tests := []struct {
+		city        string
+		wantEntries int
+	}{
+		{"Paris", 2},
+		{"Tokyo", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.city, func(t *testing.T) {
+			data, err := fetchForecastData(context.Background(), Location{Name: tt.city}, UnitsMetric, "en")
+			if err != nil {
+				t.Fatalf("fetchForecastData: %v", err)
+			}
+			if data.City.Name != tt.city || len(data.List) != tt.wantEntries {
+				t.Errorf("got %s with %d entries, want %s with %d", data.City.Name, len(data.List), tt.city, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestFetchForecastDataErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		city   string
+		apiKey string
+		check  func(error) bool
+	}{
+		{"unknown city", "Atlantis", testAPIKey, func(err error) bool { return errors.Is(err, ErrCityNotFound) }},
+		{"bad key", "Paris", "wrong-key", func(err error) bool { return errors.Is(err, ErrInvalidAPIKey) }},
+		{"malformed body", "Garbled", testAPIKey, func(err error) bool { var pe *parseError; return errors.As(err, &pe) }},
+		{"empty list", "Empty", testAPIKey, func(err error) bool { return err != nil && strings.Contains(err.Error(), "'list' missing or empty") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMockOpenWeather(t)
+			t.Setenv("WEATHER_API_KEY", tt.apiKey)
+			if _, err := fetchForecastData(context.Background(), Location{Name: tt.city}, UnitsMetric, "en"); !tt.check(err) {
+				t.Errorf("fetchForecastData(%s) = %v", tt.city, err)
+			}
+		})
+	}
+}
+
+// Point the fetchers at a server that never answers until the test ends
+func newHangingOpenWeather(t *testing.T) {
+	t.Helper()
+	newMockOpenWeather(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	openWeatherBaseURL = srv.URL
+}
+
+func TestFetchWeatherDataCancelled(t *testing.T) {
+	newHangingOpenWeather(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err := fetchWeatherData(ctx, Location{Name: "Paris"}, UnitsMetric, "en")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("fetchWeatherData with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetchWeatherData took %v to notice the cancelled context", elapsed)
+	}
+}
+
+func TestFetchWeatherDataDeadlineMidRequest(t *testing.T) {
+	newHangingOpenWeather(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := fetchWeatherData(ctx, Location{Name: "Paris"}, UnitsMetric, "en")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetchWeatherData past its deadline = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetchWeatherData took %v to give up after a 50ms deadline", elapsed)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonReport is the envelope printed in -json mode
+type jsonReport struct {
+	City       string          `json:"city"`
+	Method     string          `json:"extraction_method,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Units      Units           `json:"units"`
+	Weather    *WeatherData    `json:"weather,omitempty"`
+	Forecast   []DailyForecast `json:"forecast,omitempty"`
+	AirQuality *AirQuality     `json:"air_quality,omitempty"`
+	Note       string          `json:"note,omitempty"`
+	Usage      *Usage          `json:"usage,omitempty"`
+	Error      string          `json:"error,omitempty"` // set instead of the weather when a batch entry failed
+}
+
+// Write the report as indented JSON
+func writeJSONReport(w io.Writer, report *Report, units Units) error {
+	return writeIndentedJSON(w, newJSONReport(report, units))
+}
+
+// Build the JSON envelope for a report
+func newJSONReport(report *Report, units Units) jsonReport {
+	out := jsonReport{
+		City:       report.Location.String(),
+		Method:     string(report.Method),
+		Timestamp:  time.Now().UTC(),
+		Units:      units,
+		Weather:    report.Weather,
+		AirQuality: report.AirQuality,
+		Note:       report.Note,
+	}
+	if report.Usage.TotalTokens > 0 {
+		out.Usage = &report.Usage
+	}
+	if report.Forecast != nil {
+		out.Forecast = dailyForecasts(report.Forecast)
+		if report.Days == nil {
+			out.Forecast = firstDays(out.Forecast, report.DayCount)
+		}
+	}
+	return out
+}
+
+// Write v as indented JSON
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Default number of weather API requests in flight at the same time
+const defaultConcurrency = 5
+
+// Slots shared by all outbound weather API calls, so fan-out paths such as -batch and
+// the concurrent requests of -serve together keep at most -concurrency in flight. Set
+// from the config at startup
+var fetchSlots = newSemaphore(defaultConcurrency)
+
+// semaphore limits how many holders run at once
+type semaphore chan struct{}
+
+// Create a semaphore with n slots
+func newSemaphore(n int) semaphore {
+	return make(semaphore, max(n, 1))
+}
+
+// Acquire waits for a free slot, or returns ctx's error if ctx is done first
+func (s semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (s semaphore) Release() {
+	<-s
+}
+
+// Run fn for every index in [0, n) on at most limit goroutines at a time and wait
+// for all of them. Once ctx is done the remaining indexes are not started; fn is
+// expected to notice ctx itself for work already running
+func forEachLimit(ctx context.Context, limit, n int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < limit && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestGetJSONLimitsFetchesInFlight(t *testing.T) {
+	const limit = 2
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	oldSlots, oldLimiter := fetchSlots, rateLimiter
+	fetchSlots, rateLimiter = newSemaphore(limit), rate.NewLimiter(rate.Inf, 1)
+	defer func() { fetchSlots, rateLimiter = oldSlots, oldLimiter }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var target map[string]any
+			if err := getJSON(context.Background(), srv.URL, &target); err != nil {
+				t.Errorf("getJSON: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("%d fetches in flight at once, want at most %d", got, limit)
+	}
+}
+
+func TestSemaphoreAcquireCancelled(t *testing.T) {
+	s := newSemaphore(1)
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Acquire(ctx); err != context.Canceled {
+		t.Errorf("Acquire on a full semaphore with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+	s.Release()
+}
+
+func TestForEachLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	var done [20]atomic.Bool
+	forEachLimit(context.Background(), 3, len(done), func(i int) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		done[i].Store(true)
+	})
+	if got := peak.Load(); got > 3 {
+		t.Errorf("%d calls at once, want at most 3", got)
+	}
+	for i := range done {
+		if !done[i].Load() {
+			t.Errorf("index %d was never run", i)
+		}
+	}
+}
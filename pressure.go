@@ -0,0 +1,48 @@
+package main
+
+import "time"
+
+// Pressure change in hPa within which the pressure counts as steady
+const pressureSteadyThreshold = 1.0
+
+// Shortest time between two samples for a meaningful trend, so a reading isn't
+// compared with itself, e.g. when it came from the weather cache
+const minPressureInterval = 10 * time.Minute
+
+// How long a pressure sample is kept to compare later readings against
+const pressureSampleTTL = 3 * time.Hour
+
+// pressureSample is an earlier pressure reading for a location
+type pressureSample struct {
+	Pressure float64   `json:"pressure"`
+	At       time.Time `json:"at"`
+}
+
+// Earlier pressure readings by location key, set at startup; nil when caching is disabled
+var pressureHistory *ttlCache[pressureSample]
+
+// Compare a pressure reading with the previous sample for the location key and keep it
+// as the new sample. It returns "rising", "falling" or "steady", or an empty string when
+// there is no sample old enough to compare with
+func pressureTrend(key string, pressure float64, now time.Time) string {
+	if pressureHistory == nil {
+		return ""
+	}
+	prev, ok := pressureHistory.Get(key)
+	if ok && now.Sub(prev.At) < minPressureInterval {
+		return ""
+	}
+	pressureHistory.Set(key, pressureSample{Pressure: pressure, At: now})
+	if !ok {
+		return ""
+	}
+
+	switch delta := pressure - prev.Pressure; {
+	case delta >= pressureSteadyThreshold:
+		return "rising"
+	case delta <= -pressureSteadyThreshold:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The warning about a prompt without the weather information is returned rather than
+// logged, as the logger is not set up yet while the configuration loads
+func TestLoadResponsePromptWarnings(t *testing.T) {
+	tests := []struct {
+		name         string
+		prompt       string
+		wantWarnings int
+	}{
+		{"with weather", "Answer from this: {{.WeatherInfo}}", 0},
+		{"without weather", "Answer the question.", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "response.txt")
+			if err := os.WriteFile(path, []byte(tt.prompt), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("RESPONSE_PROMPT_FILE", path)
+			logs := captureLogs(t, slog.LevelDebug)
+
+			_, warnings, err := loadResponsePrompt()
+			if err != nil {
+				t.Fatalf("loadResponsePrompt: %v", err)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("warnings = %+v, want %d", warnings, tt.wantWarnings)
+			}
+			if got := logs.String(); got != "" {
+				t.Errorf("logged while loading:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WeatherProvider fetches current conditions for a location from some weather service
+type WeatherProvider interface {
+	Current(ctx context.Context, loc Location) (*WeatherData, error)
+}
+
+// ForecastProvider is a WeatherProvider that also fetches the daily forecast, for
+// -forecast and questions about a later day
+type ForecastProvider interface {
+	WeatherProvider
+	Forecast(ctx context.Context, loc Location) (*ForecastData, error)
+}
+
+// ErrNoForecast is returned when the configured weather provider has no forecast
+var ErrNoForecast = errors.New("the weather provider has no forecast, set WEATHER_PROVIDER to one that does")
+
+const defaultOpenWeatherBaseURL = "https://api.openweathermap.org"
+
+// Base URL of all OpenWeather requests, replaceable so they can be pointed at a mock server
+var openWeatherBaseURL = defaultOpenWeatherBaseURL
+
+// OpenWeatherProvider serves current conditions from the OpenWeather API
+type OpenWeatherProvider struct {
+	Units Units
+	Lang  string
+}
+
+// Current fetches the current weather for loc from OpenWeather
+func (p *OpenWeatherProvider) Current(ctx context.Context, loc Location) (*WeatherData, error) {
+	return fetchWeatherData(ctx, loc, p.Units, p.Lang)
+}
+
+// Forecast fetches the 5 day / 3 hour forecast for loc from OpenWeather
+func (p *OpenWeatherProvider) Forecast(ctx context.Context, loc Location) (*ForecastData, error) {
+	return fetchForecastData(ctx, loc, p.Units, p.Lang)
+}
+
+// Provider names accepted in WEATHER_PROVIDER
+const (
+	ProviderOpenWeather = "openweather"
+	ProviderOpenMeteo   = "openmeteo"
+	ProviderOneCall     = "onecall" // OpenWeather One Call 3.0, needs its own subscription
+	ProviderOffline     = "offline" // canned data, selected by OFFLINE=1
+)
+
+// Build the weather provider selected in the configuration. A comma-separated
+// list, e.g. "openweather,openmeteo", builds a chain that fails over in that order
+func newWeatherProvider(cfg *Config) (WeatherProvider, error) {
+	names := providerNames(cfg.Provider)
+	if len(names) == 1 {
+		return newNamedProvider(cfg, names[0])
+	}
+
+	chain := &ChainProvider{Names: names}
+	for _, name := range names {
+		p, err := newNamedProvider(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		chain.Providers = append(chain.Providers, p)
+	}
+	return chain, nil
+}
+
+// Constructors of the weather providers, by the name accepted in WEATHER_PROVIDER
+var namedProviders = []struct {
+	name  string
+	build func(cfg *Config) WeatherProvider
+}{
+	{ProviderOpenWeather, func(cfg *Config) WeatherProvider { return &OpenWeatherProvider{Units: cfg.Units, Lang: cfg.Lang} }},
+	{ProviderOpenMeteo, func(cfg *Config) WeatherProvider { return &OpenMeteoProvider{Units: cfg.Units} }},
+	{ProviderOneCall, func(cfg *Config) WeatherProvider { return &OneCallProvider{Units: cfg.Units, Lang: cfg.Lang} }},
+	{ProviderOffline, func(cfg *Config) WeatherProvider { return &OfflineProvider{Units: cfg.Units} }},
+}
+
+// Build a single weather provider by name
+func newNamedProvider(cfg *Config, name string) (WeatherProvider, error) {
+	var names []string
+	for _, p := range namedProviders {
+		if p.name == name {
+			return p.build(cfg), nil
+		}
+		names = append(names, p.name)
+	}
+	return nil, fmt.Errorf("unknown weather provider %q: must be one of %s", name, strings.Join(names, ", "))
+}
+
+// Split a WEATHER_PROVIDER value into provider names, in order
+func providerNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{s}
+	}
+	return names
+}
+
+// Name of the first provider tried, which decides how locations are resolved
+func (c *Config) primaryProvider() string {
+	return providerNames(c.Provider)[0]
+}
+
+// Names of the environment variables holding the API keys the configuration can't
+// serve questions without: the OpenWeather key when every provider of the chain is
+// OpenWeather's, and the Mistral key when the LLM is used
+func (c *Config) requiredAPIKeys() []string {
+	var keys []string
+	// A chain can fail over to a provider without a key, such as Open-Meteo
+	needsWeatherKey := true
+	for _, name := range providerNames(c.Provider) {
+		if name != ProviderOpenWeather && name != ProviderOneCall {
+			needsWeatherKey = false
+		}
+	}
+	if needsWeatherKey {
+		keys = append(keys, "WEATHER_API_KEY")
+	}
+	// With -no-network questions fail before reaching Mistral, so there is no key to check
+	if !c.NoLLM && !c.NoNetwork {
+		keys = append(keys, "MISTRAL_API_KEY")
+	}
+	return keys
+}
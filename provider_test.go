@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The error for an unknown name lists every name that is accepted
+func TestNewWeatherProviderUnknownName(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Provider = "openweather,accuweather"
+	_, err := newWeatherProvider(cfg)
+	if err == nil {
+		t.Fatalf("newWeatherProvider(%q) succeeded, want an error", cfg.Provider)
+	}
+	for _, p := range namedProviders {
+		if !strings.Contains(err.Error(), p.name) {
+			t.Errorf("error %q does not list %s", err, p.name)
+		}
+		cfg.Provider = p.name
+		if _, err := newWeatherProvider(cfg); err != nil {
+			t.Errorf("newWeatherProvider(%q): %v", p.name, err)
+		}
+	}
+	want := `unknown weather provider "accuweather": must be one of openweather, openmeteo, onecall, offline`
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
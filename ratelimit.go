@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// The OpenWeather free tier allows 60 calls per minute
+const (
+	defaultRateLimit = 60
+	defaultRateBurst = 10
+)
+
+// Limiter shared by all outbound weather API calls, set from the config at startup
+var rateLimiter = newRateLimiter(defaultRateLimit, defaultRateBurst)
+
+// Create a limiter allowing perMinute calls per minute with the given burst
+func newRateLimiter(perMinute, burst int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(time.Minute/time.Duration(perMinute)), burst)
+}
+
+// Parse the WEATHER_RATE_LIMIT and WEATHER_RATE_BURST environment variables
+func parseRateLimit() (perMinute, burst int, err error) {
+	perMinute, burst = defaultRateLimit, defaultRateBurst
+
+	if v := strings.TrimSpace(os.Getenv("WEATHER_RATE_LIMIT")); v != "" {
+		perMinute, err = strconv.Atoi(v)
+		if err != nil || perMinute <= 0 {
+			return 0, 0, fmt.Errorf("invalid WEATHER_RATE_LIMIT %q: must be a positive number of calls per minute", v)
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("WEATHER_RATE_BURST")); v != "" {
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst <= 0 {
+			return 0, 0, fmt.Errorf("invalid WEATHER_RATE_BURST %q: must be a positive integer", v)
+		}
+	}
+	return perMinute, burst, nil
+}
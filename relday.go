@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBeyondForecast is returned when the question asks about a day past the forecast horizon
+var ErrBeyondForecast = errors.New("that day is beyond the forecast horizon")
+
+// matches "in 3 days" style phrases
+var inDaysRe = regexp.MustCompile(`\bin (\d{1,2}) days?\b`)
+
+// Work out which days the question is about, as offsets from today: "tomorrow" is 1,
+// "this weekend" the coming Saturday and Sunday. It returns nil when no day is named
+func parseRelativeDays(userMessage string, today time.Weekday) []int {
+	msg := strings.ToLower(userMessage)
+
+	switch {
+	case strings.Contains(msg, "day after tomorrow"):
+		return []int{2}
+	case strings.Contains(msg, "tomorrow"):
+		return []int{1}
+	case strings.Contains(msg, "weekend"):
+		saturday := daysUntil(today, time.Saturday)
+		if today == time.Sunday {
+			// Only Sunday is left of this weekend
+			return []int{0}
+		}
+		return []int{saturday, saturday + 1}
+	case strings.Contains(msg, "today"), strings.Contains(msg, "tonight"):
+		return []int{0}
+	}
+
+	if m := inDaysRe.FindStringSubmatch(msg); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return []int{n}
+	}
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if regexp.MustCompile(`\b` + strings.ToLower(d.String()) + `\b`).MatchString(msg) {
+			return []int{daysUntil(today, d)}
+		}
+	}
+	return nil
+}
+
+// Number of days from one weekday to the next occurrence of another, 0 if they are the same
+func daysUntil(from, to time.Weekday) int {
+	return (int(to) - int(from) + 7) % 7
+}
+
+// Reject days, as offsets from today, that the forecast does not reach
+func checkForecastHorizon(offsets []int) error {
+	for _, o := range offsets {
+		if o >= forecastDays {
+			return fmt.Errorf("%w: the forecast covers the next %d days", ErrBeyondForecast, forecastDays)
+		}
+	}
+	return nil
+}
+
+// Report whether any of the days lies after today
+func hasFutureDay(offsets []int) bool {
+	for _, o := range offsets {
+		if o > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// session holds the state carried between questions in the interactive loop
+type session struct {
+	assistant *Assistant
+	conv      Conversation
+	out       io.Writer // where answers are written, stdout unless -output is set
+}
+
+// Answer a single weather question in the context of the earlier ones
+func (s *session) answer(ctx context.Context, userMessage string) (string, error) {
+	ctx = withRequestID(ctx, newRequestID())
+	result, err := s.assistant.Answer(ctx, userMessage, &s.conv)
+	if err != nil {
+		return "", err
+	}
+	s.conv.Record(result.Location, userMessage, result.Response)
+	s.conv.Usage.Add(result.Usage)
+	cfg := s.assistant.Config
+	return renderOutput(cfg.OutputTemplate, result, cfg.Units, cfg.NoEmoji)
+}
+
+// Answer a question, printing the LLM's answer to s.out as it is generated. stopSpinner
+// is called before the first word is printed
+func (s *session) stream(ctx context.Context, userMessage string, stopSpinner func()) error {
+	ctx = withRequestID(ctx, newRequestID())
+	cfg := s.assistant.Config
+	ctx, cancel := withQuestionTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	report, err := s.assistant.Lookup(ctx, userMessage, &s.conv)
+	if err != nil {
+		return err
+	}
+
+	streamed := false
+	onToken := func(token string) {
+		if !streamed {
+			stopSpinner()
+			if icon := weatherIcon(report.Weather, cfg.NoEmoji); icon != "" {
+				fmt.Fprint(s.out, icon, " ")
+			}
+			streamed = true
+		}
+		fmt.Fprint(s.out, token)
+	}
+	result, err := s.assistant.Respond(ctx, report, userMessage, &s.conv, onToken)
+	if streamed {
+		if err == nil && report.Note != "" {
+			fmt.Fprint(s.out, "\n\n", report.Note)
+		}
+		fmt.Fprintln(s.out)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Nothing was streamed without an LLM, so print the whole answer
+	if !streamed {
+		out, err := renderOutput(cfg.OutputTemplate, result, cfg.Units, cfg.NoEmoji)
+		if err != nil {
+			return err
+		}
+		stopSpinner()
+		fmt.Fprintln(s.out, out)
+	}
+	s.conv.Record(result.Location, userMessage, result.Response)
+	s.conv.Usage.Add(result.Usage)
+	return nil
+}
+
+// Look up the weather for a question and print it to s.out as JSON
+func (s *session) writeJSON(ctx context.Context, userMessage string) error {
+	ctx = withRequestID(ctx, newRequestID())
+	report, err := s.assistant.Lookup(ctx, userMessage, &s.conv)
+	if err != nil {
+		return err
+	}
+	s.conv.Record(report.Location, userMessage, "")
+	s.conv.Usage.Add(report.Usage)
+	return writeJSONReport(s.out, report, s.assistant.Config.Units)
+}
+
+// Turn an error into the message shown to the user
+func userErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrCityNotFound):
+		return "I couldn't find that city, try another name."
+	case errors.Is(err, ErrNoCity):
+		return "I can only help with weather — ask me about a city."
+	case errors.Is(err, ErrPostcodeCountry):
+		return "Which country is that postal code in? Add it, e.g. \"94103, US\" or \"10115 Germany\"."
+	case errors.Is(err, ErrInvalidCity):
+		return "I couldn't tell which city you meant, please rephrase your question."
+	case errors.Is(err, ErrInvalidAPIKey):
+		return "The OpenWeather API key was rejected, check WEATHER_API_KEY."
+	case errors.Is(err, ErrBeyondForecast):
+		return fmt.Sprintf("I can only look up to %d days ahead, try a closer day.", forecastDays-1)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "That took too long, try again or allow more time with -timeout."
+	case errors.Is(err, ErrLLMRateLimited):
+		return "Mistral is receiving too many requests right now, try again in a moment."
+	default:
+		return fmt.Sprint("Error: ", err)
+	}
+}
+
+// Read the first line of r, trimmed
+func readFirstLine(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	if scanner.Scan() {
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+	return "", scanner.Err()
+}
+
+// Answer a single question given on the command line, writing the answer to out, and
+// exit non-zero on failure
+func runOnce(ctx context.Context, cfg *Config, assistant *Assistant, question string, out io.Writer) {
+	s := &session{assistant: assistant, out: out}
+
+	spin := startSpinner(spinnerEnabled(cfg))
+	var response string
+	var err error
+	if cfg.JSON {
+		err = s.writeJSON(ctx, question)
+	} else {
+		response, err = s.answer(ctx, question)
+	}
+	spin.Stop()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, userErrorMessage(err))
+		os.Exit(exitCode(err))
+	}
+	if response != "" {
+		if _, err := fmt.Fprintln(out, response); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing answer:", err)
+			os.Exit(exitFailure)
+		}
+	}
+}
+
+// Read questions from in, normally stdin, until the user types exit or quit, in is
+// closed or ctx is cancelled. Answers are written to out, the prompts and messages to stdout
+func runREPL(ctx context.Context, cfg *Config, assistant *Assistant, in io.Reader, out io.Writer) {
+	s := &session{assistant: assistant, out: out}
+	defer func() {
+		if s.conv.Usage.TotalTokens > 0 {
+			s.assistant.verbosef("session token usage: %s", s.conv.Usage)
+		}
+	}()
+
+	// Read in the background so a cancelled context can interrupt the wait for input
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	// Keep stdout clean for the JSON document in -json mode
+	prompt := os.Stdout
+	if cfg.JSON {
+		prompt = os.Stderr
+	}
+
+	showSpinner := spinnerEnabled(cfg)
+	stream := streamEnabled(cfg)
+	var spin *spinner
+
+	// Ask the user about doubtful cities, reading the reply like the next question. The
+	// spinner makes way for the prompt and comes back while the question carries on
+	interactive := *assistant
+	interactive.Confirm = func(ctx context.Context, question string) bool {
+		spin.Stop()
+		fmt.Fprintf(prompt, "%s [Y/n] ", question)
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(prompt)
+			return false
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+			spin = startSpinner(showSpinner)
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "", "y", "yes":
+				return true
+			}
+			return false
+		}
+	}
+	s.assistant = &interactive
+
+	for {
+		fmt.Fprintln(prompt, "Ask about the weather")
+
+		var userMessage string
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			userMessage = strings.TrimSpace(line)
+		}
+
+		switch strings.ToLower(userMessage) {
+		case "":
+			// Nothing to ask the APIs about, just prompt again
+			fmt.Fprintln(prompt, "Please type a question about the weather.")
+			continue
+		case "exit", "quit":
+			return
+		}
+
+		// In JSON mode the data is printed as-is, skipping the second LLM call
+		if cfg.JSON {
+			spin = startSpinner(showSpinner)
+			err := s.writeJSON(ctx, userMessage)
+			spin.Stop()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(exitCode(err))
+			}
+			continue
+		}
+
+		if stream {
+			spin = startSpinner(showSpinner)
+			err := s.stream(ctx, userMessage, func() { spin.Stop() })
+			spin.Stop()
+			if ctx.Err() != nil {
+				// Interrupted with Ctrl-C
+				fmt.Println()
+				return
+			}
+			if err != nil {
+				fmt.Println(userErrorMessage(err))
+			}
+			continue
+		}
+
+		spin = startSpinner(showSpinner)
+		response, err := s.answer(ctx, userMessage)
+		spin.Stop()
+		if ctx.Err() != nil {
+			// Interrupted with Ctrl-C
+			fmt.Println()
+			return
+		}
+		if err != nil {
+			fmt.Println(userErrorMessage(err))
+			continue
+		}
+
+		// Output the final response to the user
+		fmt.Fprintln(out, response)
+	}
+}
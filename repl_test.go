@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadFirstLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"spaces only", "   \n", ""},
+		{"newline only", "\n", ""},
+		{"question", "weather in Paris\n", "weather in Paris"},
+		{"only the first line", "  weather in Paris  \nweather in Rome\n", "weather in Paris"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFirstLine(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("readFirstLine(%q) error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("readFirstLine(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// A blank line prompts again without a lookup or an LLM call, and the next question is answered
+func TestREPLSkipsBlankLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"newline only", "\n"},
+		{"spaces only", "   \n"},
+		{"tabs", "\t\t\n"},
+		{"several", "\n \t \n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			tmpl, err := parseOutputTemplate(defaultOutputTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.OutputTemplate, cfg.Quiet = tmpl, true
+			llm, provider := &fakeLLM{}, newFakeProvider()
+
+			var out strings.Builder
+			in := strings.NewReader(tt.input + "What's the weather in Paris?\n")
+			runREPL(context.Background(), cfg, NewAssistant(cfg, llm, provider), in, &out)
+
+			// One call to extract the city and one to answer, both for the question
+			if n := llm.calls.Load(); n != 2 {
+				t.Errorf("%d LLM calls, want 2 for the question alone", n)
+			}
+			if want := []string{"Paris"}; !slices.Equal(provider.asked, want) {
+				t.Errorf("provider asked about %q, want %q", provider.asked, want)
+			}
+			if !strings.Contains(out.String(), "Location: Paris") {
+				t.Errorf("output = %q, want the answer about Paris", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Default number of retries for transient OpenWeather failures
+const defaultMaxRetries = 3
+
+// Default longest wait before the first retry, doubled on every further attempt
+const defaultRetryBaseDelay = 500 * time.Millisecond
+
+// Default upper bound of the wait before any retry
+const defaultRetryMaxDelay = 10 * time.Second
+
+// Number of times a transient failure is retried and the bounds of the backoff
+// between retries, set from the config at startup
+var (
+	maxRetries     = defaultMaxRetries
+	retryBaseDelay = defaultRetryBaseDelay
+	retryMaxDelay  = defaultRetryMaxDelay
+)
+
+var (
+	// ErrCityNotFound is returned when OpenWeather does not know the requested location
+	ErrCityNotFound = errors.New("city not found")
+	// ErrInvalidAPIKey is returned when OpenWeather rejects the API key
+	ErrInvalidAPIKey = errors.New("invalid OpenWeather API key")
+	// ErrMissingAPIKey is returned when a required API key is not configured
+	ErrMissingAPIKey = errors.New("missing API key")
+)
+
+// statusError is returned when an upstream API answers with a non-200 status
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d, response: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap maps well-known statuses onto sentinel errors so callers can use errors.Is
+func (e *statusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrCityNotFound
+	case http.StatusUnauthorized:
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// Report whether err is worth retrying: rate limiting, server errors and network errors are,
+// anything else (bad key, unknown city, malformed body, -no-network) is not
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrNetworkDisabled) {
+		return false
+	}
+
+	var se *statusError
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var pe *parseError
+	return !errors.As(err, &pe)
+}
+
+// Run op, retrying transient failures with exponential backoff until it succeeds,
+// the retries are exhausted or ctx is cancelled
+func withRetry(ctx context.Context, retries int, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= retries || !isRetryable(err) {
+			return err
+		}
+
+		delay := backoff(attempt, retryBaseDelay, retryMaxDelay)
+		slog.WarnContext(ctx, "retrying after error", "attempt", attempt+1, "of", retries, "wait", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Pick the wait before retry number attempt+1 with "full jitter": a random duration
+// up to base doubled attempt times, capped at maxDelay. The randomness keeps clients that
+// failed together, e.g. during an outage, from retrying in lockstep
+func backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	ceiling := maxDelay
+	if attempt < 32 && base<<attempt < maxDelay && base<<attempt > 0 {
+		ceiling = base << attempt
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	const base, maxDelay = 100 * time.Millisecond, 2 * time.Second
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, 1600 * time.Millisecond},
+		{5, maxDelay},
+		{31, maxDelay},
+		{32, maxDelay},
+		{1000, maxDelay},
+	}
+	for _, tt := range tests {
+		var longest time.Duration
+		for i := 0; i < 1000; i++ {
+			d := backoff(tt.attempt, base, maxDelay)
+			if d < 0 || d > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [0, %v]", tt.attempt, d, tt.ceiling)
+			}
+			longest = max(longest, d)
+		}
+		// Full jitter spreads the waits over the whole range
+		if longest < tt.ceiling/2 {
+			t.Errorf("backoff(%d) never went past %v in 1000 tries, want up to %v", tt.attempt, longest, tt.ceiling)
+		}
+	}
+}
+
+func TestBackoffHugeBase(t *testing.T) {
+	// Shifting a large base overflows, which must not turn into a negative wait
+	for attempt := 0; attempt < 70; attempt++ {
+		if d := backoff(attempt, time.Duration(1<<62), time.Hour); d < 0 || d > time.Hour {
+			t.Fatalf("backoff(%d) = %v, want within [0, 1h]", attempt, d)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	oldBase, oldMax := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = time.Millisecond, time.Millisecond
+	defer func() { retryBaseDelay, retryMaxDelay = oldBase, oldMax }()
+
+	unavailable := &statusError{StatusCode: http.StatusServiceUnavailable}
+	tests := []struct {
+		name      string
+		errs      []error // returned by the attempts in turn, then nil
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", nil, nil, 1},
+		{"transient, then success", []error{unavailable, unavailable}, nil, 3},
+		{"retries exhausted", []error{unavailable, unavailable, unavailable, unavailable}, unavailable, 3},
+		{"not retryable", []error{&statusError{StatusCode: http.StatusNotFound}}, ErrCityNotFound, 1},
+		{"malformed body", []error{&parseError{Err: errors.New("bad")}}, &parseError{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(context.Background(), 2, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("%d attempts, want %d", calls, tt.wantCalls)
+			}
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("withRetry = %v, want nil", err)
+				}
+			case *parseError:
+				var pe *parseError
+				if !errors.As(err, &pe) {
+					t.Errorf("withRetry = %v, want a parse error", err)
+				}
+			default:
+				if !errors.Is(err, want) {
+					t.Errorf("withRetry = %v, want %v", err, want)
+				}
+			}
+		})
+	}
+}
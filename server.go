@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// How long the optional upstream check of /readyz may take, so probes don't hang
+const readyzUpstreamTimeout = 2 * time.Second
+
+// How long in-flight requests may run after a shutdown signal before they are cut off
+const shutdownTimeout = 15 * time.Second
+
+// weatherRequest is the JSON body accepted by POST /weather
+type weatherRequest struct {
+	Message string `json:"message"`
+}
+
+// weatherResponse is the JSON body returned by POST /weather
+type weatherResponse struct {
+	RequestID string `json:"request_id"`
+	City      string `json:"city"`
+	Response  string `json:"response"`
+}
+
+// statusResponse is the JSON body returned by the health endpoints
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// errorResponse is the JSON body returned when a request fails
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+// errorDetail describes a failure with a stable code clients can match on
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error codes returned in errorDetail.Code:
+//
+//	BAD_REQUEST          400  the request body is not valid JSON or has no message
+//	METHOD_NOT_ALLOWED   405  the endpoint was called with the wrong HTTP method
+//	NO_CITY              422  the message does not mention a city
+//	INVALID_CITY         422  the extracted city is not a plausible place name
+//	CITY_NOT_FOUND       404  the weather service does not know the city
+//	BEYOND_FORECAST      422  the message asks about a day past the forecast horizon
+//	INVALID_API_KEY      500  the weather service rejected the configured API key
+//	MISSING_API_KEY      500  an API key is not configured
+//	NOT_READY            503  the server is not ready to serve requests
+//	LLM_RATE_LIMITED     503  Mistral is rate limiting us, retry later
+//	LLM_ERROR            502  the Mistral request failed
+//	TIMEOUT              504  the question could not be answered in time
+//	UPSTREAM_ERROR       502  the weather service failed or returned something unusable
+const (
+	codeBadRequest       = "BAD_REQUEST"
+	codeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	codeNoCity           = "NO_CITY"
+	codeInvalidCity      = "INVALID_CITY"
+	codeCityNotFound     = "CITY_NOT_FOUND"
+	codeBeyondForecast   = "BEYOND_FORECAST"
+	codeInvalidAPIKey    = "INVALID_API_KEY"
+	codeMissingAPIKey    = "MISSING_API_KEY"
+	codeNotReady         = "NOT_READY"
+	codeLLMRateLimited   = "LLM_RATE_LIMITED"
+	codeLLMError         = "LLM_ERROR"
+	codeTimeout          = "TIMEOUT"
+	codeUpstreamError    = "UPSTREAM_ERROR"
+)
+
+// Map an error from answering a question to its HTTP status and error code
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, codeTimeout
+	case errors.Is(err, ErrNoCity):
+		return http.StatusUnprocessableEntity, codeNoCity
+	case errors.Is(err, ErrInvalidCity):
+		return http.StatusUnprocessableEntity, codeInvalidCity
+	case errors.Is(err, ErrCityNotFound):
+		return http.StatusNotFound, codeCityNotFound
+	case errors.Is(err, ErrBeyondForecast):
+		return http.StatusUnprocessableEntity, codeBeyondForecast
+	case errors.Is(err, ErrInvalidAPIKey):
+		return http.StatusInternalServerError, codeInvalidAPIKey
+	case errors.Is(err, ErrMissingAPIKey):
+		return http.StatusInternalServerError, codeMissingAPIKey
+	case errors.Is(err, ErrLLMRateLimited):
+		return http.StatusServiceUnavailable, codeLLMRateLimited
+	case errors.Is(err, ErrLLM):
+		return http.StatusBadGateway, codeLLMError
+	default:
+		return http.StatusBadGateway, codeUpstreamError
+	}
+}
+
+// Start the HTTP server exposing the assistant and block until it stops or ctx is cancelled
+func serve(ctx context.Context, cfg *Config, assistant *Assistant) error {
+	mux := http.NewServeMux()
+	mux.Handle("/weather", promhttp.InstrumentHandlerDuration(requestDuration,
+		promhttp.InstrumentHandlerCounter(requestsTotal, weatherHandler(assistant))))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.Handle("/readyz", readyzHandler(cfg))
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: mux}
+	errc := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", cfg.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting connections and give in-flight requests time to finish
+	slog.Info("shutting down, draining in-flight requests", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Closing the remaining connections cancels their request contexts
+		srv.Close()
+		return fmt.Errorf("forced shutdown after %s: %w", shutdownTimeout, err)
+	}
+	slog.Info("server stopped")
+	return nil
+}
+
+// Handle POST /weather by running the message through the assistant pipeline.
+// Requests run concurrently and all share the one assistant and its Mistral client
+func weatherHandler(assistant *Assistant) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := newRequestID()
+		ctx := withRequestID(r.Context(), id)
+		w.Header().Set("X-Request-ID", id)
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "only POST is supported")
+			return
+		}
+
+		var req weatherRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, codeBadRequest, "invalid JSON body: "+err.Error())
+			return
+		}
+		if strings.TrimSpace(req.Message) == "" {
+			writeJSONError(w, http.StatusBadRequest, codeBadRequest, "message must not be empty")
+			return
+		}
+
+		// Each request stands alone, so there is no previous location to fall back to
+		result, err := assistant.Answer(ctx, req.Message, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "error answering request", "error", err)
+			status, code := errorStatus(err)
+			if code == codeLLMRateLimited {
+				w.Header().Set("Retry-After", "10")
+			}
+			writeJSONError(w, status, code, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, weatherResponse{RequestID: id, City: result.Location.String(), Response: result.Response})
+	}
+}
+
+// Handle GET /healthz, which succeeds as long as the process is serving requests
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// Handle GET /readyz, which succeeds once the API keys the weather providers and the
+// LLM need are configured. With ?upstream=1 it also checks that the OpenWeather API
+// can be reached
+func readyzHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, key := range cfg.requiredAPIKeys() {
+			if _, err := getAPIKey(key); err != nil {
+				writeJSONError(w, http.StatusServiceUnavailable, codeNotReady, err.Error())
+				return
+			}
+		}
+
+		if r.URL.Query().Get("upstream") != "" {
+			if err := pingUpstream(r.Context()); err != nil {
+				writeJSONError(w, http.StatusServiceUnavailable, codeNotReady, err.Error())
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, statusResponse{Status: "ready"})
+	}
+}
+
+// Check that the OpenWeather host answers at all. Any HTTP response counts, the
+// check is only about connectivity
+func pingUpstream(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readyzUpstreamTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, openWeatherBaseURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OpenWeather API unreachable: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Write v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("error writing response", "error", err)
+	}
+}
+
+// Write a JSON error body with the given status code
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorResponse{Error: errorDetail{Code: code, Message: message}})
+}
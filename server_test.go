@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// POST a message to the /weather endpoint of srv, returning the status and decoded body
+func postWeather(t *testing.T, srv *httptest.Server, message string) (int, weatherResponse, errorResponse) {
+	t.Helper()
+	body, _ := json.Marshal(weatherRequest{Message: message})
+	resp, err := http.Post(srv.URL+"/weather", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Errorf("POST /weather: %v", err)
+		return 0, weatherResponse{}, errorResponse{}
+	}
+	defer resp.Body.Close()
+
+	var ok weatherResponse
+	var failed errorResponse
+	if resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(&ok)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&failed)
+	}
+	if err != nil {
+		t.Errorf("decoding the response to %q: %v", message, err)
+	}
+	return resp.StatusCode, ok, failed
+}
+
+// Start the /weather endpoint backed by a fake LLM and the fake provider
+func newTestServer(t *testing.T) (*httptest.Server, *fakeLLM) {
+	t.Helper()
+	llm := &fakeLLM{}
+	mux := http.NewServeMux()
+	mux.Handle("/weather", weatherHandler(NewAssistant(testConfig(t), llm, newFakeProvider())))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, llm
+}
+
+// Run with -race: the requests share the assistant and its LLM client
+func TestWeatherHandlerConcurrentRequests(t *testing.T) {
+	srv, llm := newTestServer(t)
+
+	cities := []string{"Paris", "London", "Tokyo"}
+	const perCity = 10
+	var wg sync.WaitGroup
+	for i := 0; i < perCity; i++ {
+		for _, city := range cities {
+			wg.Add(1)
+			go func(city string) {
+				defer wg.Done()
+				status, resp, failed := postWeather(t, srv, fmt.Sprintf("What's the weather like in %s today?", city))
+				if status != http.StatusOK {
+					t.Errorf("%s: status %d, error %+v", city, status, failed.Error)
+					return
+				}
+				if resp.City != city {
+					t.Errorf("%s: answered about %q", city, resp.City)
+				}
+				if !strings.Contains(resp.Response, "Location: "+city+"\n") {
+					t.Errorf("%s: response %q is about another city", city, resp.Response)
+				}
+				if resp.RequestID == "" {
+					t.Errorf("%s: no request ID", city)
+				}
+			}(city)
+		}
+	}
+	wg.Wait()
+
+	// One extraction and one answer per request
+	if got, want := int(llm.calls.Load()), 2*perCity*len(cities); got != want {
+		t.Errorf("%d LLM calls, want %d", got, want)
+	}
+}
+
+func TestWeatherHandlerErrors(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		message    string
+		wantStatus int
+		wantCode   string
+	}{
+		{"empty message", "  ", http.StatusBadRequest, codeBadRequest},
+		{"no city", "Will I need an umbrella?", http.StatusUnprocessableEntity, codeNoCity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, _, failed := postWeather(t, srv, tt.message)
+			if status != tt.wantStatus || failed.Error.Code != tt.wantCode {
+				t.Errorf("got %d %s, want %d %s", status, failed.Error.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+
+	t.Run("GET", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/weather")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+			t.Errorf("got %d with Allow %q, want %d with Allow POST", resp.StatusCode, resp.Header.Get("Allow"), http.StatusMethodNotAllowed)
+		}
+	})
+	t.Run("invalid JSON", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/weather", "application/json", strings.NewReader(`{"message":`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("got %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+// Only the keys the providers and the LLM need are required to be ready
+func TestReadyzHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		noLLM      bool
+		keys       []string // set in the environment, all others unset
+		wantStatus int
+	}{
+		{"openweather without keys", ProviderOpenWeather, false, nil, http.StatusServiceUnavailable},
+		{"openweather without the LLM", ProviderOpenWeather, true, []string{"WEATHER_API_KEY"}, http.StatusOK},
+		{"openweather with the LLM", ProviderOpenWeather, false, []string{"WEATHER_API_KEY", "MISTRAL_API_KEY"}, http.StatusOK},
+		{"onecall without its key", ProviderOneCall, true, nil, http.StatusServiceUnavailable},
+		{"openmeteo", ProviderOpenMeteo, true, nil, http.StatusOK},
+		{"openmeteo without the Mistral key", ProviderOpenMeteo, false, nil, http.StatusServiceUnavailable},
+		{"openmeteo with the LLM", ProviderOpenMeteo, false, []string{"MISTRAL_API_KEY"}, http.StatusOK},
+		{"offline", ProviderOffline, true, nil, http.StatusOK},
+		{"chain falling over to openmeteo", "openweather,openmeteo", true, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"WEATHER_API_KEY", "MISTRAL_API_KEY"} {
+				t.Setenv(key, "")
+				t.Setenv(key+"_FILE", "")
+			}
+			for _, key := range tt.keys {
+				t.Setenv(key, "test-key")
+			}
+			cfg := testConfig(t)
+			cfg.Provider, cfg.NoLLM = tt.provider, tt.noLLM
+
+			rec := httptest.NewRecorder()
+			readyzHandler(cfg)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const spinnerInterval = 100 * time.Millisecond
+
+var spinnerFrames = []rune{'⠋', '⠙', '⠹', '⠸', '⠼', '⠴', '⠦', '⠧', '⠇', '⠏'}
+
+// spinner draws a "thinking…" animation on stderr while a request is in flight
+type spinner struct {
+	stop chan struct{}
+	done sync.WaitGroup
+	once sync.Once
+}
+
+// Start a spinner, or return nil when it is disabled. A nil spinner is safe to stop
+func startSpinner(enabled bool) *spinner {
+	if !enabled {
+		return nil
+	}
+
+	s := &spinner{stop: make(chan struct{})}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(spinnerInterval)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%c thinking…", spinnerFrames[i%len(spinnerFrames)])
+			select {
+			case <-s.stop:
+				// Clear the line before the answer is printed
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop the spinner and wait until its line is cleared. Stopping it again does nothing
+func (s *spinner) Stop() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() { close(s.stop) })
+	s.done.Wait()
+}
+
+// Only animate when a person is watching, so piped output stays clean. Verbose
+// output shares stderr with the spinner, so it turns the spinner off too
+func spinnerEnabled(cfg *Config) bool {
+	return !cfg.Quiet && !cfg.Verbose && isTerminal(os.Stdout) && isTerminal(os.Stderr)
+}
+
+// Stream answers word by word when a person is reading them. A custom -template needs
+// the whole answer, -json prints data rather than the answer, and -output sends it elsewhere
+func streamEnabled(cfg *Config) bool {
+	return cfg.Stream && !cfg.JSON && cfg.Output == "" && isTerminal(os.Stdout)
+}
+
+// Report whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Output template matching the plain answer, prefixed with the weather icon
+const defaultOutputTemplate = "{{with .Icon}}{{.}} {{end}}{{.LLMResponse}}"
+
+// outputData is the data available to the -template output template
+type outputData struct {
+	City        string
+	Description string
+	Temp        float64
+	FeelsLike   float64
+	Humidity    float64
+	Units       string // temperature symbol, e.g. ℃
+	Icon        string // emoji for the conditions, a text marker with -no-emoji, or empty
+	LLMResponse string
+}
+
+// Parse the -template value, which is either the path of a template file or the template itself
+func parseOutputTemplate(s string) (*template.Template, error) {
+	text := s
+	if info, err := os.Stat(s); err == nil && !info.IsDir() {
+		b, err := os.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	return template.New("output").Option("missingkey=error").Parse(text)
+}
+
+// Render the answer through the output template
+func renderOutput(tmpl *template.Template, result *Result, units Units, ascii bool) (string, error) {
+	data := outputData{
+		City:        result.Location.String(),
+		Units:       units.Symbol(),
+		Icon:        weatherIcon(result.Weather, ascii),
+		LLMResponse: result.Response,
+	}
+	if w := result.Weather; w != nil {
+		if w.Name != "" {
+			data.City = w.Name
+		}
+		if m := w.Main; m != nil {
+			// Readings the provider left out render as zero
+			if m.Temp != nil {
+				data.Temp = *m.Temp
+			}
+			if m.FeelsLike != nil {
+				data.FeelsLike = *m.FeelsLike
+			}
+			if m.Humidity != nil {
+				data.Humidity = *m.Humidity
+			}
+		}
+		data.Description = w.description()
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render output template: %w", err)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default total time allowed for answering one question
+const defaultTimeout = 30 * time.Second
+
+// Stage timeout used when the context carries no overall deadline
+const defaultStageTimeout = 10 * time.Second
+
+// Shares of the remaining budget given to each stage. Extraction leaves room for the
+// fetch and the answer, generation is the last stage and may use everything left
+const (
+	extractBudgetShare  = 1.0 / 3
+	generateBudgetShare = 1.0
+)
+
+// Derive the context for one stage, allowed share of the time left until ctx's deadline
+func stageContext(ctx context.Context, share float64) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithTimeout(ctx, defaultStageTimeout)
+	}
+	return context.WithTimeout(ctx, time.Duration(float64(time.Until(deadline))*share))
+}
+
+// Apply the overall question deadline, unless ctx already has one
+func withQuestionTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	q := &questionDeadline{parent: ctx, done: make(chan struct{}), deadline: time.Now().Add(timeout)}
+	q.mu.Lock()
+	q.timer = time.AfterFunc(timeout, func() { q.finish(context.DeadlineExceeded) })
+	q.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.finish(ctx.Err())
+		case <-q.done:
+		}
+	}()
+	return q, func() { q.finish(context.Canceled) }
+}
+
+// questionDeadline is the context of one question, cancelled when its time is up.
+// Unlike a context.WithTimeout its clock can be paused, so the time the user takes to
+// answer a prompt does not count against -timeout
+type questionDeadline struct {
+	parent context.Context
+	done   chan struct{}
+	timer  *time.Timer
+
+	mu       sync.Mutex
+	err      error
+	deadline time.Time
+	left     time.Duration // time left while paused, 0 when running
+}
+
+type questionDeadlineKey struct{}
+
+func (q *questionDeadline) Deadline() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.left > 0 {
+		return time.Now().Add(q.left), true
+	}
+	return q.deadline, true
+}
+
+func (q *questionDeadline) Done() <-chan struct{} { return q.done }
+
+func (q *questionDeadline) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+func (q *questionDeadline) Value(key any) any {
+	if key == (questionDeadlineKey{}) {
+		return q
+	}
+	return q.parent.Value(key)
+}
+
+// End the question with err, unless it has already ended
+func (q *questionDeadline) finish(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return
+	}
+	q.timer.Stop()
+	q.err = err
+	close(q.done)
+}
+
+// Stop the clock of the question deadline ctx runs under, if any, until the returned
+// function is called. Cancellation, e.g. by Ctrl-C, still ends the question meanwhile
+func pauseDeadline(ctx context.Context) (resume func()) {
+	q, ok := ctx.Value(questionDeadlineKey{}).(*questionDeadline)
+	if !ok {
+		return func() {}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil || q.left > 0 || !q.timer.Stop() {
+		// Already over, already paused or about to time out
+		return func() {}
+	}
+	q.left = time.Until(q.deadline)
+	return func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.err != nil {
+			return
+		}
+		q.deadline = time.Now().Add(q.left)
+		q.timer.Reset(q.left)
+		q.left = 0
+	}
+}
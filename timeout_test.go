@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuestionTimeoutExpires(t *testing.T) {
+	ctx, cancel := withQuestionTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	stage, stageCancel := context.WithCancel(ctx)
+	defer stageCancel()
+
+	select {
+	case <-stage.Done():
+	case <-time.After(time.Second):
+		t.Fatal("question deadline never expired")
+	}
+	if !errors.Is(stage.Err(), context.DeadlineExceeded) {
+		t.Errorf("stage error = %v, want %v", stage.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestPauseDeadline(t *testing.T) {
+	ctx, cancel := withQuestionTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resume := pauseDeadline(ctx)
+	time.Sleep(100 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("question ended while paused: %v", err)
+	}
+	resume()
+
+	deadline, ok := ctx.Deadline()
+	if left := time.Until(deadline); !ok || left <= 0 || left > 50*time.Millisecond {
+		t.Errorf("time left after resuming = %v, want up to 50ms", left)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("question deadline never expired after resuming")
+	}
+}
+
+func TestPauseDeadlineStillCancels(t *testing.T) {
+	parent, interrupt := context.WithCancel(context.Background())
+	ctx, cancel := withQuestionTimeout(parent, time.Minute)
+	defer cancel()
+
+	resume := pauseDeadline(ctx)
+	defer resume()
+	interrupt()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("paused question ignored cancellation")
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("error = %v, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestPauseDeadlineWithoutQuestion(t *testing.T) {
+	// Contexts without a question deadline are left alone
+	pauseDeadline(context.Background())()
+}
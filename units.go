@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Units is an OpenWeather unit system
+type Units string
+
+const (
+	UnitsMetric   Units = "metric"
+	UnitsImperial Units = "imperial"
+	UnitsStandard Units = "standard"
+)
+
+// Parse a unit system name, accepting metric, imperial or standard
+func parseUnits(s string) (Units, error) {
+	switch u := Units(strings.ToLower(strings.TrimSpace(s))); u {
+	case UnitsMetric, UnitsImperial, UnitsStandard:
+		return u, nil
+	default:
+		return "", fmt.Errorf("unknown units %q: must be one of metric, imperial, standard", s)
+	}
+}
+
+// Parse the -units value: a single unit system, a comma-separated list of them, or
+// "both" for metric and imperial. Weather is fetched in the first, the others are
+// shown alongside it
+func parseUnitsList(s string) ([]Units, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "both") {
+		return []Units{UnitsMetric, UnitsImperial}, nil
+	}
+	var list []Units
+	for _, part := range strings.Split(s, ",") {
+		u, err := parseUnits(part)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(list, u) {
+			list = append(list, u)
+		}
+	}
+	return list, nil
+}
+
+// Symbol returns the temperature symbol for the unit system
+func (u Units) Symbol() string {
+	switch u {
+	case UnitsImperial:
+		return "℉"
+	case UnitsStandard:
+		return "K"
+	default:
+		return "℃"
+	}
+}
+
+// Default number of decimals shown for temperatures
+const defaultTempPrecision = 1
+
+// Largest accepted -precision value
+const maxTempPrecision = 4
+
+// WindSymbol returns the wind speed unit for the unit system
+func (u Units) WindSymbol() string {
+	if u == UnitsImperial {
+		return "mph"
+	}
+	return "m/s"
+}
+
+// Convert a wind speed between unit systems: mph for imperial, m/s otherwise
+func convertWind(speed float64, from, to Units) float64 {
+	ms := windSpeedMS(speed, from)
+	if to == UnitsImperial {
+		return ms / 0.44704
+	}
+	return ms
+}
+
+// CelsiusToFahrenheit converts a temperature from ℃ to ℉
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+// FahrenheitToCelsius converts a temperature from ℉ to ℃
+func FahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+// ToKelvin converts a temperature in the given unit system to K
+func ToKelvin(t float64, from Units) float64 {
+	switch from {
+	case UnitsImperial:
+		return FahrenheitToCelsius(t) + 273.15
+	case UnitsStandard:
+		return t
+	default:
+		return t + 273.15
+	}
+}
+
+// Convert a temperature between unit systems, going through Kelvin
+func convertTemp(t float64, from, to Units) float64 {
+	if from == to {
+		return t
+	}
+	k := ToKelvin(t, from)
+	switch to {
+	case UnitsImperial:
+		return CelsiusToFahrenheit(k - 273.15)
+	case UnitsStandard:
+		return k
+	default:
+		return k - 273.15
+	}
+}
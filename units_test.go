@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Tolerance for comparing converted temperatures
+const tempEpsilon = 1e-9
+
+func TestCelsiusToFahrenheit(t *testing.T) {
+	tests := []struct{ c, want float64 }{
+		{0, 32},
+		{100, 212},
+		{-40, -40},
+		{37, 98.6},
+		{-17.5, 0.5},
+		{20.25, 68.45},
+	}
+	for _, tt := range tests {
+		if got := CelsiusToFahrenheit(tt.c); math.Abs(got-tt.want) > tempEpsilon {
+			t.Errorf("CelsiusToFahrenheit(%v) = %v, want %v", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestFahrenheitToCelsius(t *testing.T) {
+	tests := []struct{ f, want float64 }{
+		{32, 0},
+		{212, 100},
+		{-40, -40},
+		{0, -17.77777777777778},
+		{98.6, 37},
+		{-459.67, -273.15},
+	}
+	for _, tt := range tests {
+		if got := FahrenheitToCelsius(tt.f); math.Abs(got-tt.want) > tempEpsilon {
+			t.Errorf("FahrenheitToCelsius(%v) = %v, want %v", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestToKelvin(t *testing.T) {
+	tests := []struct {
+		t    float64
+		from Units
+		want float64
+	}{
+		{0, UnitsMetric, 273.15},
+		{-273.15, UnitsMetric, 0},
+		{-10.5, UnitsMetric, 262.65},
+		{32, UnitsImperial, 273.15},
+		{-459.67, UnitsImperial, 0},
+		{300, UnitsStandard, 300},
+	}
+	for _, tt := range tests {
+		if got := ToKelvin(tt.t, tt.from); math.Abs(got-tt.want) > tempEpsilon {
+			t.Errorf("ToKelvin(%v, %s) = %v, want %v", tt.t, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTemp(t *testing.T) {
+	tests := []struct {
+		t        float64
+		from, to Units
+		want     float64
+	}{
+		{20, UnitsMetric, UnitsMetric, 20},
+		{20, UnitsMetric, UnitsImperial, 68},
+		{-5, UnitsMetric, UnitsImperial, 23},
+		{-40, UnitsImperial, UnitsMetric, -40},
+		{50, UnitsImperial, UnitsStandard, 283.15},
+		{0, UnitsStandard, UnitsMetric, -273.15},
+		{255.37, UnitsStandard, UnitsImperial, -0.004},
+	}
+	for _, tt := range tests {
+		if got := convertTemp(tt.t, tt.from, tt.to); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("convertTemp(%v, %s, %s) = %v, want %v", tt.t, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// Conversions show up rounded in answers, e.g. -3℃ in Fahrenheit
+func TestConvertTempRounded(t *testing.T) {
+	f := Formatter{Units: UnitsImperial, Precision: 1}
+	tests := []struct {
+		c    float64
+		want string
+	}{
+		{-3, "26.6℉"},
+		{-17.8, "0℉"},
+		{-18, "-0.4℉"},
+		{36.6, "97.9℉"},
+	}
+	for _, tt := range tests {
+		if got := f.Temp(convertTemp(tt.c, UnitsMetric, UnitsImperial)); got != tt.want {
+			t.Errorf("%v℃ = %s, want %s", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnitsList(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []Units
+		wantErr bool
+	}{
+		{"metric", []Units{UnitsMetric}, false},
+		{" Imperial ", []Units{UnitsImperial}, false},
+		{"both", []Units{UnitsMetric, UnitsImperial}, false},
+		{"standard,metric,standard", []Units{UnitsStandard, UnitsMetric}, false},
+		{"kelvin", nil, true},
+		{"metric,", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseUnitsList(tt.s)
+		if (err != nil) != tt.wantErr || len(got) != len(tt.want) {
+			t.Errorf("parseUnitsList(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseUnitsList(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		}
+	}
+}
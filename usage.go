@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Help text printed before the flag list by -h
+const usageHeader = `weather-assistant answers questions about the weather in plain language.
+
+It works out which city a question is about with a Mistral model, looks up the
+weather on OpenWeather (or Open-Meteo) and has the model phrase the answer.
+
+Usage:
+  weather-assistant [flags]              ask questions interactively
+  weather-assistant [flags] "question"   answer one question and exit
+  weather-assistant -batch file [flags]  look up every city listed in file
+  weather-assistant -serve [flags]       serve POST /weather over HTTP
+
+Required environment (also read from a .env file):
+  MISTRAL_API_KEY       Mistral API key; without it the tool runs as with -no-llm
+  WEATHER_API_KEY       OpenWeather API key
+  MISTRAL_API_KEY_FILE, WEATHER_API_KEY_FILE
+                        files holding the keys instead, e.g. mounted secrets;
+                        they take precedence over the variables above
+
+Optional environment:
+  WEATHER_UNITS, WEATHER_LANG, MISTRAL_MODEL, MISTRAL_TEMPERATURE, MISTRAL_MAX_TOKENS
+                        defaults for the flags of the same name
+  WEATHER_PROVIDER      openweather, openmeteo or onecall, or a comma-separated
+                        list to fail over in order (default openweather)
+  WEATHER_API_BASE_URL  OpenWeather host, e.g. for a proxy or mock server
+  WEATHER_USER_AGENT    User-Agent of outbound requests (default weather-assistant/<version>)
+  WEATHER_HTTP_TIMEOUT, WEATHER_MAX_RETRIES, WEATHER_RATE_LIMIT, WEATHER_RATE_BURST
+                        tuning of the outbound weather API requests
+  WEATHER_RETRY_BASE, WEATHER_RETRY_MAX
+                        bounds of the randomized wait between retries (default 500ms, 10s)
+  WEATHER_CACHE_TTL, WEATHER_CACHE_SIZE
+                        lifetime and size of the weather cache
+  EXTRACT_PROMPT_FILE, RESPONSE_PROMPT_FILE
+                        files replacing the built-in system prompts
+  WEATHER_GEOIP_URL     IP geolocation service of -auto-location (default ipapi.co).
+                        Privacy: with -auto-location your IP address is sent to it
+                        whenever a question names no city
+  OFFLINE=1             demo mode with made-up weather and no API keys
+  LOG_LEVEL, LOG_FORMAT log verbosity (debug, info, warn, error) and format (text, json)
+
+Flags:
+`
+
+// Print the help text and the flag defaults
+func printUsage() {
+	fmt.Fprint(flag.CommandLine.Output(), usageHeader)
+	flag.PrintDefaults()
+}
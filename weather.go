@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// WeatherData mirrors the parts of the OpenWeather current weather response we use
+type WeatherData struct {
+	Name          string             `json:"name"`
+	Coord         *Coord             `json:"coord,omitempty"`
+	Main          *MainData          `json:"main"`
+	Weather       []WeatherCondition `json:"weather"`
+	Wind          *WindData          `json:"wind,omitempty"`
+	Clouds        *CloudsData        `json:"clouds,omitempty"`
+	Rain          *PrecipData        `json:"rain,omitempty"`
+	Snow          *PrecipData        `json:"snow,omitempty"`
+	Sys           *SysData           `json:"sys,omitempty"`
+	Visibility    *float64           `json:"visibility,omitempty"` // in metres
+	Timezone      int                `json:"timezone"`
+	Alerts        []Alert            `json:"alerts,omitempty"`
+	PressureTrend string             `json:"pressure_trend,omitempty"` // rising, falling or steady since an earlier reading
+}
+
+// Coord holds the coordinates OpenWeather resolved the location to
+type Coord struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// MainData holds the "main" block with temperature and humidity readings
+// The readings are typed as float64 so whole numbers such as "temp":20 decode
+// the same way as "temp":20.5. Each is nil when the API leaves it out
+type MainData struct {
+	Temp      *float64 `json:"temp,omitempty"`
+	FeelsLike *float64 `json:"feels_like,omitempty"`
+	Humidity  *float64 `json:"humidity,omitempty"`
+	TempMin   *float64 `json:"temp_min,omitempty"`
+	TempMax   *float64 `json:"temp_max,omitempty"`
+	Pressure  *float64 `json:"pressure,omitempty"` // sea level, in hPa
+}
+
+// WeatherCondition is a single entry of the "weather" array
+type WeatherCondition struct {
+	ID          int    `json:"id"`
+	Main        string `json:"main"`
+	Description string `json:"description"`
+}
+
+// WindData holds the "wind" block. Deg is nil when no direction is reported
+type WindData struct {
+	Speed float64  `json:"speed"`
+	Deg   *float64 `json:"deg,omitempty"`
+}
+
+// Alert is an official weather warning, only reported by the One Call API
+type Alert struct {
+	SenderName  string `json:"sender_name"`
+	Event       string `json:"event"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	Description string `json:"description"`
+}
+
+// Longest alert description included in the summary, they can run to several paragraphs
+const maxAlertDescription = 300
+
+// Describe active weather alerts, one per line, or return "" when there are none
+func formatAlerts(alerts []Alert, offsetSeconds int) string {
+	var lines []string
+	for _, a := range alerts {
+		line := "WEATHER ALERT: " + a.Event
+		if a.SenderName != "" {
+			line += " from " + a.SenderName
+		}
+		if a.End != 0 {
+			line += ", until " + formatLocalTime(a.End, offsetSeconds) + " local time"
+		}
+		if desc := strings.Join(strings.Fields(a.Description), " "); desc != "" {
+			if r := []rune(desc); len(r) > maxAlertDescription {
+				desc = string(r[:maxAlertDescription]) + "…"
+			}
+			line += ": " + desc
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CloudsData holds the "clouds" block with the cloud cover in percent
+type CloudsData struct {
+	All float64 `json:"all"`
+}
+
+// PrecipData holds the "rain" or "snow" block, only present when there was some
+type PrecipData struct {
+	OneHour float64 `json:"1h"`
+}
+
+// SysData holds the "sys" block with sunrise and sunset as Unix timestamps
+type SysData struct {
+	Country string `json:"country"`
+	Sunrise int64  `json:"sunrise"`
+	Sunset  int64  `json:"sunset"`
+}
+
+// Format a Unix timestamp as "HH:MM" in the local time of a location
+// that is offsetSeconds east of UTC
+func formatLocalTime(ts int64, offsetSeconds int) string {
+	return time.Unix(ts, 0).In(time.FixedZone("", offsetSeconds)).Format("15:04")
+}
+
+var compassPoints = []string{"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE", "S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW"}
+
+// Convert a bearing in degrees to a 16-point compass direction, e.g. 315 -> "NW"
+func degreesToCompass(deg float64) string {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	// Each point covers 22.5 degrees centred on its bearing
+	return compassPoints[int(math.Round(deg/22.5))%len(compassPoints)]
+}
+
+// Check that the fields required to describe the weather are present
+func (d *WeatherData) validate() error {
+	if d == nil {
+		return fmt.Errorf("unexpected response format: no weather data")
+	}
+	// Anything else missing is left out of the summary rather than failing the question
+	if d.Main == nil && d.description() == "" {
+		return fmt.Errorf("unexpected response format: both 'main' and 'weather' missing or empty")
+	}
+	return nil
+}
+
+// Describe the current conditions, or return an empty string when they are not reported
+func (d *WeatherData) description() string {
+	if len(d.Weather) == 0 {
+		return ""
+	}
+	return d.Weather[0].Description
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeWholeNumberReadings(t *testing.T) {
+	body := `{"name":"Oslo","main":{"temp":20,"feels_like":19,"humidity":55,"temp_min":18,"temp_max":22,"pressure":1013},"weather":[{"id":800,"main":"Clear","description":"clear sky"}],"wind":{"speed":3,"deg":90}}`
+
+	var data WeatherData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		t.Fatalf("decoding whole-number readings: %v", err)
+	}
+	m := data.Main
+	if *m.Temp != 20 || *m.FeelsLike != 19 || *m.Humidity != 55 || *m.TempMin != 18 || *m.TempMax != 22 || *m.Pressure != 1013 {
+		t.Errorf("main = %+v, want the readings of the body", m)
+	}
+	if data.Wind.Speed != 3 || *data.Wind.Deg != 90 {
+		t.Errorf("wind = %+v, want 3 from 90°", data.Wind)
+	}
+}
+
+func TestDecodeFractionalReadings(t *testing.T) {
+	var data WeatherData
+	if err := json.Unmarshal([]byte(`{"main":{"temp":20.5,"feels_like":-0.25,"humidity":55,"temp_min":18,"temp_max":22,"pressure":1013}}`), &data); err != nil {
+		t.Fatalf("decoding fractional readings: %v", err)
+	}
+	if *data.Main.Temp != 20.5 || *data.Main.FeelsLike != -0.25 {
+		t.Errorf("main = %+v, want temp 20.5 and feels like -0.25", data.Main)
+	}
+}
+
+func TestDegreesToCompass(t *testing.T) {
+	tests := []struct {
+		deg  float64
+		want string
+	}{
+		{0, "N"},
+		{11.2, "N"},
+		{11.3, "NNE"},
+		{22.5, "NNE"},
+		{45, "NE"},
+		{90, "E"},
+		{180, "S"},
+		{270, "W"},
+		{315, "NW"},
+		{348.7, "NNW"},
+		{348.8, "N"},
+		{359, "N"},
+		{360, "N"},
+		{405, "NE"},
+		{-90, "W"},
+	}
+	for _, tt := range tests {
+		if got := degreesToCompass(tt.deg); got != tt.want {
+			t.Errorf("degreesToCompass(%v) = %s, want %s", tt.deg, got, tt.want)
+		}
+	}
+}
+
+func TestFormatLocalTime(t *testing.T) {
+	// 2026-10-16 23:30:00 UTC
+	const lateUTC = 1792193400
+	// 2026-10-17 00:15:00 UTC
+	const earlyUTC = 1792196100
+
+	tests := []struct {
+		name   string
+		ts     int64
+		offset int
+		want   string
+	}{
+		{"UTC", lateUTC, 0, "23:30"},
+		{"east, past midnight", lateUTC, 2 * 3600, "01:30"},
+		{"half-hour offset past midnight", lateUTC, 5*3600 + 1800, "05:00"},
+		{"far east", lateUTC, 14 * 3600, "13:30"},
+		{"west, same day", lateUTC, -5 * 3600, "18:30"},
+		{"just past midnight UTC", earlyUTC, 0, "00:15"},
+		{"west, back before midnight", earlyUTC, -3600, "23:15"},
+		{"far west", earlyUTC, -12 * 3600, "12:15"},
+		{"quarter-hour offset", earlyUTC, 5*3600 + 45*60, "06:00"},
+		{"epoch", 0, 0, "00:00"},
+	}
+	for _, tt := range tests {
+		if got := formatLocalTime(tt.ts, tt.offset); got != tt.want {
+			t.Errorf("%s: formatLocalTime(%d, %d) = %s, want %s", tt.name, tt.ts, tt.offset, got, tt.want)
+		}
+	}
+}